@@ -0,0 +1,26 @@
+resource "etcd_acl_policy" "apps" {
+    mode = "authoritative"
+    policy = <<EOT
+roles:
+  - name: app1
+    permissions:
+      - permission: readwrite
+        key: /apps/app1/
+        prefix: true
+      - permission: read
+        key: /shared/config
+        range_end: /shared/config
+  - name: app2
+    permissions:
+      - permission: readwrite
+        key: /apps/app2/
+        prefix: true
+users:
+  - username: app1
+    roles: [app1]
+    no_password: true
+  - username: app2
+    roles: [app2]
+    no_password: true
+EOT
+}
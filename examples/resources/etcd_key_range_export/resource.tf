@@ -0,0 +1,11 @@
+data "etcd_prefix_range_end" "app_config" {
+  key = "/app/config/"
+}
+
+resource "etcd_key_range_export" "app_config" {
+    key = data.etcd_prefix_range_end.app_config.key
+    range_end = data.etcd_prefix_range_end.app_config.range_end
+    file = "${path.module}/snapshots/app-config.yml"
+    format = "yaml"
+    recurrence = "onchange"
+}
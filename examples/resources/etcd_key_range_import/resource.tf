@@ -0,0 +1,15 @@
+data "etcd_prefix_range_end" "app_config" {
+  key = "/app/config/"
+}
+
+//Content of app-config.jsonl:
+//{"key": "/app/config/log_level", "value": "aW5mbw=="}
+//{"key": "/app/config/maintenance", "value": "ZmFsc2U=", "lease_ttl": 3600}
+resource "etcd_key_range_import" "app_config" {
+    key = data.etcd_prefix_range_end.app_config.key
+    range_end = data.etcd_prefix_range_end.app_config.range_end
+    file = "${path.module}/app-config.jsonl"
+    format = "jsonl"
+    prune = true
+    recurrence = "onchange"
+}
@@ -0,0 +1,16 @@
+resource "etcd_lock" "confs" {
+    name = "/locks/confs"
+    ttl = 900
+    timeout = "10m"
+}
+
+resource "etcd_key_prefix" "confs" {
+    prefix = "/confs/"
+
+    keys {
+        key = "app1.yml"
+        value = "port: 8080"
+    }
+
+    depends_on = [etcd_lock.confs]
+}
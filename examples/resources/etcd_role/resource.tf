@@ -11,6 +11,12 @@ resource "etcd_role" "configurator" {
         range_end = data.etcd_prefix_range_end.conf_files.range_end
     }
 
+    permissions {
+        permission = "read"
+        key = "/reports/"
+        prefix = true
+    }
+
     permissions {
         permission = "read"
         key = "/summary.txt"
@@ -0,0 +1,11 @@
+data "etcd_prefix_range_end" "app_config" {
+    key = "/app/config/"
+}
+
+//The monitoring role is shared with other projects that grant it other permissions
+resource "etcd_role_permission" "monitoring_app_config" {
+    role = "monitoring"
+    permission = "read"
+    key = data.etcd_prefix_range_end.app_config.key
+    range_end = data.etcd_prefix_range_end.app_config.range_end
+}
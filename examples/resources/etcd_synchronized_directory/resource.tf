@@ -5,6 +5,7 @@ resource "etcd_synchronized_directory" "prometheus_confs" {
     key_prefix = "/prometheus-confs/"
     source = "directory"
     recurrence = "once"
+    compression = "gzip"
 }
 
 //sync key range in etcdnew with the one in etcdold
@@ -0,0 +1,26 @@
+resource "etcd_transaction" "bootstrap" {
+    compare {
+        key = "/cluster/initialized"
+        target = "version"
+        operator = "="
+        value = "0"
+    }
+
+    success {
+        type = "put"
+        key = "/cluster/initialized"
+        value = "true"
+    }
+
+    success {
+        type = "put"
+        key = "/cluster/config"
+        value = "replicas: 3"
+    }
+
+    failure {
+        type = "delete_range"
+        key = "/cluster/bootstrap/"
+        range_end = "/cluster/bootstrap0"
+    }
+}
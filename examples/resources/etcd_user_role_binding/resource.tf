@@ -0,0 +1,15 @@
+resource "etcd_role" "ci_deployer" {
+    name = "ci-deployer"
+
+    permissions {
+        permission = "readwrite"
+        key = "/deployments/"
+        range_end = "/deployments0"
+    }
+}
+
+//The ci user is shared with other projects that grant it other roles
+resource "etcd_user_role_binding" "ci_deployer" {
+    username = "ci"
+    role = etcd_role.ci_deployer.name
+}
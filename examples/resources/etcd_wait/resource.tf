@@ -0,0 +1,6 @@
+resource "etcd_wait" "cluster_ready" {
+    key = "/cluster/ready"
+    condition = "equals"
+    value = "true"
+    timeout = "15m"
+}
@@ -9,4 +9,7 @@ func main() {
 	plugin.Serve(&plugin.ServeOpts{
 		ProviderFunc: provider.Provider,
 	})
+
+	//Serve returns when terraform shuts the provider down gracefully
+	provider.ReleaseAllSessionLocks()
 }
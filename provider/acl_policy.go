@@ -0,0 +1,259 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
+	yaml "gopkg.in/yaml.v3"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type AclPolicyDocumentPermission struct {
+	Permission string `json:"permission" yaml:"permission"`
+	Key        string `json:"key" yaml:"key"`
+	RangeEnd   string `json:"range_end,omitempty" yaml:"range_end,omitempty"`
+	Prefix     bool   `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+}
+
+type AclPolicyDocumentRole struct {
+	Name        string                        `json:"name" yaml:"name"`
+	Permissions []AclPolicyDocumentPermission `json:"permissions" yaml:"permissions"`
+}
+
+type AclPolicyDocumentUser struct {
+	Username   string   `json:"username" yaml:"username"`
+	Roles      []string `json:"roles" yaml:"roles"`
+	NoPassword bool     `json:"no_password,omitempty" yaml:"no_password,omitempty"`
+}
+
+/*
+Acl policy document as written by the user, in yaml or json.
+A permission can either have a range end or be a prefix.
+*/
+type AclPolicyDocument struct {
+	Roles []AclPolicyDocumentRole `json:"roles" yaml:"roles"`
+	Users []AclPolicyDocumentUser `json:"users" yaml:"users"`
+}
+
+/*
+Normalized acl policy where prefixes are converted to range ends
+and roles, users, permissions and user roles are sorted.
+The user passwords are not part of the policy, but the users that can be created without a password are.
+*/
+type AclPolicy struct {
+	Roles           []client.EtcdRole
+	Users           []client.EtcdUser
+	NoPasswordUsers []string
+}
+
+func (policy AclPolicy) GetRole(name string) (client.EtcdRole, bool) {
+	for _, role := range policy.Roles {
+		if role.Name == name {
+			return role, true
+		}
+	}
+
+	return client.EtcdRole{Name: name, Permissions: []client.EtcdRolePermission{}}, false
+}
+
+func (policy AclPolicy) GetUser(username string) (client.EtcdUser, bool) {
+	for _, user := range policy.Users {
+		if user.Username == username {
+			return user, true
+		}
+	}
+
+	return client.EtcdUser{Username: username, Roles: []string{}}, false
+}
+
+func (policy AclPolicy) Equals(other AclPolicy) bool {
+	return reflect.DeepEqual(policy, other)
+}
+
+//Hash of the policy rendered as json so that it does not depend on the formatting of the policy document
+func (policy AclPolicy) GetId() (string, error) {
+	rendered, err := RenderAclPolicy(policy)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256([]byte(rendered))
+	return hex.EncodeToString(hash[:]), nil
+}
+
+func sortRolePermissions(permissions []client.EtcdRolePermission) {
+	sort.SliceStable(permissions, func(i, j int) bool {
+		if permissions[i].Key != permissions[j].Key {
+			return permissions[i].Key < permissions[j].Key
+		}
+		return permissions[i].RangeEnd < permissions[j].RangeEnd
+	})
+}
+
+func (policy *AclPolicy) normalize() {
+	for idx := range policy.Roles {
+		sortRolePermissions(policy.Roles[idx].Permissions)
+	}
+	sort.SliceStable(policy.Roles, func(i, j int) bool {
+		return policy.Roles[i].Name < policy.Roles[j].Name
+	})
+
+	for idx := range policy.Users {
+		sort.Strings(policy.Users[idx].Roles)
+	}
+	sort.SliceStable(policy.Users, func(i, j int) bool {
+		return policy.Users[i].Username < policy.Users[j].Username
+	})
+	sort.Strings(policy.NoPasswordUsers)
+}
+
+func aclPolicyPermissionToModel(roleName string, permission AclPolicyDocumentPermission) (client.EtcdRolePermission, error) {
+	if permission.Permission != "read" && permission.Permission != "write" && permission.Permission != "readwrite" {
+		return client.EtcdRolePermission{}, errors.New(fmt.Sprintf("Permission of role '%s' on key '%s' can only be one of the followings: read, write, readwrite", roleName, permission.Key))
+	}
+
+	if permission.Key == "" {
+		return client.EtcdRolePermission{}, errors.New(fmt.Sprintf("Permission of role '%s' is missing a key", roleName))
+	}
+
+	rangeEnd := permission.RangeEnd
+	if permission.Prefix {
+		if rangeEnd != "" {
+			return client.EtcdRolePermission{}, errors.New(fmt.Sprintf("Permission of role '%s' on key '%s' cannot have both a range_end and prefix set to true", roleName, permission.Key))
+		}
+		rangeEnd = clientv3.GetPrefixRangeEnd(permission.Key)
+	}
+
+	if rangeEnd == "" {
+		return client.EtcdRolePermission{}, errors.New(fmt.Sprintf("Permission of role '%s' on key '%s' must have either a range_end or prefix set to true", roleName, permission.Key))
+	}
+
+	err := KeyRange{Key: permission.Key, RangeEnd: rangeEnd}.Validate()
+	if err != nil {
+		return client.EtcdRolePermission{}, errors.New(fmt.Sprintf("Permission of role '%s' on key '%s' is invalid: %s", roleName, permission.Key, err.Error()))
+	}
+
+	return client.EtcdRolePermission{Permission: permission.Permission, Key: permission.Key, RangeEnd: rangeEnd}, nil
+}
+
+func ParseAclPolicy(document string) (AclPolicy, error) {
+	var doc AclPolicyDocument
+	policy := AclPolicy{Roles: []client.EtcdRole{}, Users: []client.EtcdUser{}, NoPasswordUsers: []string{}}
+
+	//Json documents are valid yaml
+	dec := yaml.NewDecoder(strings.NewReader(document))
+	dec.KnownFields(true)
+	err := dec.Decode(&doc)
+	if err != nil && err != io.EOF {
+		return policy, errors.New(fmt.Sprintf("Error parsing acl policy: %s", err.Error()))
+	}
+
+	for _, docRole := range doc.Roles {
+		if docRole.Name == "" {
+			return policy, errors.New("Roles of acl policy must have a name")
+		}
+
+		//Etcd requires the root role to enable authentication so it is left out of reach of policies
+		if docRole.Name == "root" {
+			return policy, errors.New("The root role cannot be part of an acl policy")
+		}
+
+		if _, exists := policy.GetRole(docRole.Name); exists {
+			return policy, errors.New(fmt.Sprintf("Role '%s' is defined more than once in acl policy", docRole.Name))
+		}
+
+		role := client.EtcdRole{Name: docRole.Name, Permissions: []client.EtcdRolePermission{}}
+		for _, docPermission := range docRole.Permissions {
+			permission, err := aclPolicyPermissionToModel(role.Name, docPermission)
+			if err != nil {
+				return policy, err
+			}
+
+			//Etcd keeps a single permission per key range
+			for _, other := range role.Permissions {
+				if other.Key == permission.Key && other.RangeEnd == permission.RangeEnd {
+					return policy, errors.New(fmt.Sprintf("Role '%s' has more than one permission on key range (key='%s', range_end='%s')", role.Name, permission.Key, permission.RangeEnd))
+				}
+			}
+
+			role.Permissions = append(role.Permissions, permission)
+		}
+
+		policy.Roles = append(policy.Roles, role)
+	}
+
+	for _, docUser := range doc.Users {
+		if docUser.Username == "" {
+			return policy, errors.New("Users of acl policy must have a username")
+		}
+
+		if docUser.Username == "root" {
+			return policy, errors.New("The root user cannot be part of an acl policy")
+		}
+
+		if _, exists := policy.GetUser(docUser.Username); exists {
+			return policy, errors.New(fmt.Sprintf("User '%s' is defined more than once in acl policy", docUser.Username))
+		}
+
+		user := client.EtcdUser{Username: docUser.Username, Roles: []string{}}
+		for _, role := range docUser.Roles {
+			if role == "" {
+				return policy, errors.New(fmt.Sprintf("User '%s' has a role without a name", user.Username))
+			}
+
+			if !slices.Contains(user.Roles, role) {
+				user.Roles = append(user.Roles, role)
+			}
+		}
+
+		policy.Users = append(policy.Users, user)
+		if docUser.NoPassword {
+			policy.NoPasswordUsers = append(policy.NoPasswordUsers, user.Username)
+		}
+	}
+
+	policy.normalize()
+	return policy, nil
+}
+
+//Renders the policy as a json document with explicit range ends
+func RenderAclPolicy(policy AclPolicy) (string, error) {
+	doc := AclPolicyDocument{Roles: []AclPolicyDocumentRole{}, Users: []AclPolicyDocumentUser{}}
+
+	for _, role := range policy.Roles {
+		docRole := AclPolicyDocumentRole{Name: role.Name, Permissions: []AclPolicyDocumentPermission{}}
+		for _, permission := range role.Permissions {
+			docRole.Permissions = append(docRole.Permissions, AclPolicyDocumentPermission{
+				Permission: permission.Permission,
+				Key:        permission.Key,
+				RangeEnd:   permission.RangeEnd,
+			})
+		}
+		doc.Roles = append(doc.Roles, docRole)
+	}
+
+	for _, user := range policy.Users {
+		doc.Users = append(doc.Users, AclPolicyDocumentUser{
+			Username:   user.Username,
+			Roles:      user.Roles,
+			NoPassword: slices.Contains(policy.NoPasswordUsers, user.Username),
+		})
+	}
+
+	output, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("Error rendering acl policy: %s", err.Error()))
+	}
+
+	return string(output), nil
+}
@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
+)
+
+/*
+Usernames of the clients that authenticate with a client certificate, indexed by client.
+Etcd takes the username from the common name of the certificate, which the client does not expose once connected.
+*/
+var clientCertUsernames = struct {
+	sync.Mutex
+	usernames map[*client.EtcdClient]string
+}{usernames: make(map[*client.EtcdClient]string)}
+
+//Returns the common name of the first certificate of the given pem file
+func GetCertCommonName(certPath string) (string, error) {
+	content, err := os.ReadFile(certPath)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("Error reading client certificate %s: %s", certPath, err.Error()))
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return "", errors.New(fmt.Sprintf("Error reading client certificate %s: no pem encoded certificate found", certPath))
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("Error parsing client certificate %s: %s", certPath, err.Error()))
+	}
+
+	return cert.Subject.CommonName, nil
+}
+
+func SetClientCertUsername(cli *client.EtcdClient, username string) {
+	clientCertUsernames.Lock()
+	defer clientCertUsernames.Unlock()
+	clientCertUsernames.usernames[cli] = username
+}
+
+/*
+Returns the username etcd authenticates the client as.
+A username given with a password takes precedence over the common name of the client certificate.
+Returns an empty string if the client authenticates with neither.
+*/
+func GetClientUsername(cli *client.EtcdClient) string {
+	if cli.Client.Username != "" {
+		return cli.Client.Username
+	}
+
+	clientCertUsernames.Lock()
+	defer clientCertUsernames.Unlock()
+	return clientCertUsernames.usernames[cli]
+}
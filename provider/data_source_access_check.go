@@ -0,0 +1,191 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func dataSourceAccessCheck() *schema.Resource {
+	return &schema.Resource{
+		Description: "Checks whether a user is granted an operation on a key or key range by its roles. Like etcd, the permissions of all the roles of the user are combined and a user with the root role is granted everything. Note that whether authentication is enabled on the cluster is not taken into account.",
+		Read:        dataSourceAccessCheckRead,
+		Schema: map[string]*schema.Schema{
+			"username": {
+				Description:  "Name of the user to check the access of.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"key": {
+				Description:  "Key to check the access on or beginning of the key range to check the access on.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"range_end": {
+				Description:   "End of the key range to check the access on (exclusive). If omitted, the access is checked on the key only.",
+				Type:          schema.TypeString,
+				Optional:      true,
+				Default:       "",
+				ConflictsWith: []string{"prefix"},
+			},
+			"prefix": {
+				Description:   "If set to true, the access is checked on all the keys prefixed by the key.",
+				Type:          schema.TypeBool,
+				Optional:      true,
+				Default:       false,
+				ConflictsWith: []string{"range_end"},
+			},
+			"operation": {
+				Description:  "Operation to check the access for. Can be: read or write",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringInSlice([]string{"read", "write"}, false),
+			},
+			"allowed": {
+				Description: "Whether the user is granted the operation on the whole key range.",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+			"matching_role": {
+				Description: "Role of the user granting the operation on the key range. It is empty if the access is denied or if it is only granted by a combination of several permissions.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"matching_key": {
+				Description: "Beginning of the key range of the permission granting the operation. It is empty if matching_role is empty or if it is the root role.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"matching_range_end": {
+				Description: "End of the key range of the permission granting the operation. It is empty if matching_role is empty or if it is the root role.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+type AccessCheckId struct {
+	Username  string
+	Operation string
+	Key       string
+	RangeEnd  string
+}
+
+//Needed to absolutely ensure it is deterministic
+func (id AccessCheckId) MarshalJSON() ([]byte, error) {
+	mUsername, _ := json.Marshal(id.Username)
+	mOperation, _ := json.Marshal(id.Operation)
+	mKey, _ := json.Marshal(id.Key)
+	mRangeEnd, _ := json.Marshal(id.RangeEnd)
+	return []byte(fmt.Sprintf("{\"Username\":%s,\"Operation\":%s,\"Key\":%s,\"RangeEnd\":%s}", string(mUsername), string(mOperation), string(mKey), string(mRangeEnd))), nil
+}
+
+func (id AccessCheckId) Serialize() string {
+	out, _ := json.Marshal(id)
+	return string(out)
+}
+
+type AccessCheckResult struct {
+	Allowed          bool
+	MatchingRole     string
+	MatchingKey      string
+	MatchingRangeEnd string
+}
+
+func permissionGrantsOperation(permission string, operation string) bool {
+	return permission == "readwrite" || permission == operation
+}
+
+func CheckUserAccess(cli *client.EtcdClient, username string, operation string, keyRange KeyRange) (AccessCheckResult, error) {
+	resRoles, userExists, err := cli.GetUserRoles(username)
+	if err != nil {
+		return AccessCheckResult{}, errors.New(fmt.Sprintf("Error retrieving user '%s': %s", username, err.Error()))
+	}
+
+	if !userExists {
+		return AccessCheckResult{}, errors.New(fmt.Sprintf("Error retrieving user '%s': it was not found", username))
+	}
+
+	if slices.Contains(resRoles, "root") {
+		return AccessCheckResult{Allowed: true, MatchingRole: "root"}, nil
+	}
+
+	granted := []client.EtcdRolePermission{}
+	for _, role := range resRoles {
+		resPermissions, roleExists, err := cli.GetRolePermissions(role)
+		if err != nil {
+			return AccessCheckResult{}, errors.New(fmt.Sprintf("Error retrieving role '%s' of user '%s': %s", role, username, err.Error()))
+		}
+
+		if !roleExists {
+			continue
+		}
+
+		for _, resPermission := range resPermissions {
+			if !permissionGrantsOperation(resPermission.Permission, operation) {
+				continue
+			}
+
+			if (KeyRange{resPermission.Key, resPermission.RangeEnd}).ContainsRange(keyRange) {
+				return AccessCheckResult{
+					Allowed:          true,
+					MatchingRole:     role,
+					MatchingKey:      resPermission.Key,
+					MatchingRangeEnd: resPermission.RangeEnd,
+				}, nil
+			}
+
+			granted = append(granted, resPermission)
+		}
+	}
+
+	//The range may still be covered by several adjacent or overlapping permissions
+	for _, grantedRange := range GetEffectiveRanges(granted)[operation] {
+		if grantedRange.ContainsRange(keyRange) {
+			return AccessCheckResult{Allowed: true}, nil
+		}
+	}
+
+	return AccessCheckResult{Allowed: false}, nil
+}
+
+func dataSourceAccessCheckRead(d *schema.ResourceData, meta interface{}) error {
+	cli := meta.(*client.EtcdClient)
+	username := d.Get("username").(string)
+	operation := d.Get("operation").(string)
+	key := d.Get("key").(string)
+	rangeEnd := d.Get("range_end").(string)
+	if d.Get("prefix").(bool) {
+		rangeEnd = clientv3.GetPrefixRangeEnd(key)
+	}
+
+	keyRange := KeyRange{Key: key, RangeEnd: rangeEnd}
+	err := keyRange.Validate()
+	if err != nil {
+		return err
+	}
+
+	d.SetId(AccessCheckId{username, operation, key, rangeEnd}.Serialize())
+
+	result, err := CheckUserAccess(cli, username, operation, keyRange)
+	if err != nil {
+		return err
+	}
+
+	d.Set("allowed", result.Allowed)
+	d.Set("matching_role", result.MatchingRole)
+	d.Set("matching_key", result.MatchingKey)
+	d.Set("matching_range_end", result.MatchingRangeEnd)
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func dataSourceElectionLeader() *schema.Resource {
+	return &schema.Resource{
+		Description: "Retrieves the current leader of an election held with the etcd concurrency package (or the etcdctl elect command). As with the concurrency package, the leader is the candidate whose key was created first under the election prefix.",
+		Read:        dataSourceElectionLeaderRead,
+		Schema: map[string]*schema.Schema{
+			"prefix": {
+				Description:  "Prefix of the election, as passed to the concurrency package. As with the concurrency package, the keys of the candidates are looked up under the prefix followed by a slash.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"must_exist": &schema.Schema{
+				Description: "Whether to cause an error if the election has no leader.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+			},
+			"key": {
+				Description: "Key of the leader's candidacy.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"value": {
+				Description: "Value proclaimed by the leader.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"lease": {
+				Description: "Id of the lease of the leader's session.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"create_revision": {
+				Description: "Revision of the etcd keystore when the leader became a candidate.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"mod_revision": {
+				Description: "Revision of the etcd keystore when the leader last proclaimed its value.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"found": &schema.Schema{
+				Description: "Whether the election has a leader.",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+			"header_revision": {
+				Description: "Revision of the etcd keystore when the leader was read.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func dataSourceElectionLeaderRead(d *schema.ResourceData, meta interface{}) error {
+	cli := meta.(*client.EtcdClient)
+	prefix := d.Get("prefix").(string)
+	mustExist := d.Get("must_exist").(bool)
+
+	d.SetId(prefix)
+
+	//Same lookup as the concurrency package's election, without creating a session
+	res, err := getWithRetries(cli, prefix+"/", clientv3.WithFirstCreate(), 0, cli.Retries)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error retrieving leader of election '%s': %s", prefix, err.Error()))
+	}
+
+	d.Set("header_revision", res.Header.Revision)
+
+	if len(res.Kvs) == 0 {
+		if mustExist {
+			return errors.New(fmt.Sprintf("Error retrieving leader of election '%s': there is no leader", prefix))
+		}
+
+		d.Set("found", false)
+		return nil
+	}
+
+	leader := keyValueToKeyInfo(res.Kvs[0])
+	d.Set("key", leader.Key)
+	d.Set("value", leader.Value)
+	d.Set("lease", leader.Lease)
+	d.Set("create_revision", leader.CreateRevision)
+	d.Set("mod_revision", leader.ModRevision)
+	d.Set("found", true)
+
+	return nil
+}
@@ -11,7 +11,7 @@ import (
 
 func dataSourceKey() *schema.Resource {
 	return &schema.Resource{
-		Description: "Retrieves information about a key.",
+		Description: "Retrieves information about a key. As the plugin sdk does not support dynamic attributes, the decoded value of the key is exposed as a json string in the decoded attribute, which the jsondecode function turns into a terraform value.",
 		Read:        dataSourceKeyRead,
 		Schema: map[string]*schema.Schema{
 			"key": {
@@ -26,11 +26,30 @@ func dataSourceKey() *schema.Resource {
 				Optional:    true,
 				Default:     true,
 			},
+			"revision": &schema.Schema{
+				Description:  "Revision of the etcd keystore at which to read the key. Defaults to 0 which reads the latest revision.",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"value_format": &schema.Schema{
+				Description:  "Format of the value of the key used to populate the decoded attribute. Can be one of: raw, json, yaml. Defaults to raw. An error naming the key is returned if the value cannot be parsed in the given format.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "raw",
+				ValidateFunc: validateValueFormat,
+			},
 			"value": &schema.Schema{
 				Description: "Value of the key.",
 				Type:        schema.TypeString,
 				Computed:    true,
 			},
+			"decoded": &schema.Schema{
+				Description: "Value of the key decoded according to the value_format argument and normalized as json. Use the jsondecode function to access its content. Given that the plugin sdk does not support dynamic attributes, this is guaranteed to be valid json which jsondecode can always process. It is the json null value if the key is not found.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
 			"version": {
 				Description: "Current version of the key. Note that version is reset to 0 on deletion",
 				Type:        schema.TypeInt,
@@ -56,32 +75,65 @@ func dataSourceKey() *schema.Resource {
 				Type:        schema.TypeBool,
 				Computed:    true,
 			},
+			"header_revision": {
+				Description: "Revision of the etcd keystore at which the key was read. It is the revision argument if it is set and the latest revision of the keystore otherwise. It can be passed as the revision argument of other data sources to read them consistently.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
 		},
 	}
 }
 
+/*
+Returns the key along with the revision of the store at which it was read.
+A key read at a given revision is read at that revision. Otherwise, it is read as a single key range to know the revision of the store.
+*/
+func getKeyWithHeaderRevision(cli *client.EtcdClient, key string, revision int64) (client.KeyInfo, int64, error) {
+	if revision > 0 {
+		keyInfo, err := cli.GetKey(key, client.GetKeyOptions{Revision: revision})
+		return keyInfo, revision, err
+	}
+
+	keyInfos, err := cli.GetKeyRange(key, key+"\x00")
+	if err != nil {
+		return client.KeyInfo{}, -1, err
+	}
+
+	return keyInfos.Keys[key], keyInfos.Revision, nil
+}
+
 func dataSourceKeyRead(d *schema.ResourceData, meta interface{}) error {
 	cli := meta.(*client.EtcdClient)
 	key := d.Get("key").(string)
 	mustExist := d.Get("must_exist").(bool)
+	revision := int64(d.Get("revision").(int))
 
 	d.SetId(key)
 
-	keyInfo, err := cli.GetKey(key, client.GetKeyOptions{})
+	keyInfo, headerRevision, err := getKeyWithHeaderRevision(cli, key, revision)
 	if err != nil {
 		return errors.New(fmt.Sprintf("Error retrieving key '%s': %s", key, err.Error()))
 	}
 
+	d.Set("header_revision", headerRevision)
+
 	if !keyInfo.Found() {
 		if mustExist {
 			return errors.New(fmt.Sprintf("Error retrieving key '%s': it was not found", key))
 		}
 
+		d.Set("decoded", "null")
 		d.Set("found", false)
 		return nil
 	}
 
+	decoded, decodeErr := DecodeKeyValue(key, keyInfo.Value, d.Get("value_format").(string))
+	if decodeErr != nil {
+		return decodeErr
+	}
+
 	d.Set("value", keyInfo.Value)
+	d.Set("decoded", decoded)
 	d.Set("version", keyInfo.Version)
 	d.Set("create_revision", keyInfo.CreateRevision)
 	d.Set("mod_revision", keyInfo.ModRevision)
@@ -3,7 +3,7 @@ package provider
 import (
 	"errors"
 	"fmt"
-	"sort"
+	"regexp"
 
 	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
@@ -27,6 +27,69 @@ func dataSourceKeyRange() *schema.Resource {
 				Required:     true,
 				ValidateFunc: validation.StringIsNotEmpty,
 			},
+			"revision": &schema.Schema{
+				Description:  "Revision of the etcd keystore at which to read the key range. Defaults to 0 which reads the latest revision.",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"limit": &schema.Schema{
+				Description:  "Maximum number of keys to return in the results. Defaults to 0 which returns all the keys.",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"keys_only": &schema.Schema{
+				Description: "Whether to omit the values of the keys in the results.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"count_only": &schema.Schema{
+				Description: "Whether to only count the keys without returning them in the results.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"sort_order": &schema.Schema{
+				Description: "Order in which the results are sorted by key. Can be one of: ascend, descend. Defaults to ascend.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "ascend",
+				ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+					sortOrder := val.(string)
+					if sortOrder != "ascend" && sortOrder != "descend" {
+						return []string{}, []error{errors.New("The sort_order field must be one of the following: ascend, descend")}
+					}
+					return []string{}, []error{}
+				},
+			},
+			"key_regex": &schema.Schema{
+				Description:  "Regular expression that the keys must match to be returned in the results and counted.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "",
+				ValidateFunc: validation.StringIsValidRegExp,
+			},
+			"value_format": &schema.Schema{
+				Description:  "Format of the values of the keys used to populate the decoded attribute of each result. Can be one of: raw, json, yaml. Defaults to raw. An error naming the key is returned if a value cannot be parsed in the given format. Values are not decoded if keys_only is set and the decoded attribute of each result is then null.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "raw",
+				ValidateFunc: validateValueFormat,
+			},
+			"keys_count": {
+				Description: "Number of keys in the range matching the key_regex filter. It is not capped by the limit. It is not named count as terraform reserves that name.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"header_revision": {
+				Description: "Revision of the etcd keystore at which the key range was read. It is the revision argument if it is set and the latest revision of the keystore otherwise. It can be passed as the revision argument of other data sources to read them consistently.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
 			"results": &schema.Schema{
 				Description: "List of keys that were read. Note that numerical values returned by etcd are in int64 format which might cause problems in int32 platforms.",
 				Type:        schema.TypeList,
@@ -43,6 +106,11 @@ func dataSourceKeyRange() *schema.Resource {
 							Type:        schema.TypeString,
 							Computed:    true,
 						},
+						"decoded": {
+							Description: "Value of the key decoded according to the value_format argument and normalized as json. When keys_only is set, it is the json null value so that jsondecode can still process it.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
 						"version": {
 							Description: "Current version of the key. Note that version is reset to 0 on deletion",
 							Type:        schema.TypeInt,
@@ -74,31 +142,43 @@ func dataSourceKeyRangeRead(d *schema.ResourceData, meta interface{}) error {
 	cli := meta.(*client.EtcdClient)
 	key := d.Get("key").(string)
 	rangeEnd := d.Get("range_end").(string)
+	revision := int64(d.Get("revision").(int))
+	keyRegex := d.Get("key_regex").(string)
+	valueFormat := d.Get("value_format").(string)
+
+	opts := KeyRangeQueryOptions{
+		Revision:   revision,
+		Limit:      int64(d.Get("limit").(int)),
+		KeysOnly:   d.Get("keys_only").(bool),
+		CountOnly:  d.Get("count_only").(bool),
+		Descending: d.Get("sort_order").(string) == "descend",
+	}
+	if keyRegex != "" {
+		opts.KeyFilter = regexp.MustCompile(keyRegex)
+	}
 
 	d.SetId(KeyRangeId{key, rangeEnd}.Serialize())
 
-	keyInfos, err := cli.GetKeyRange(key, rangeEnd)
+	keyInfos, err := QueryKeyRange(cli, key, rangeEnd, opts)
 	if err != nil {
 		return errors.New(fmt.Sprintf("Error retrieving key range (key='%s', range_end='%s'): %s", key, rangeEnd, err.Error()))
 	}
 
-	sorted := make([]client.KeyInfo, len(keyInfos.Keys))
-	idx := 0
-	for _, keyInfo := range keyInfos.Keys {
-		sorted[idx] = keyInfo
-		idx++
-	}
-	sort.SliceStable(sorted, func(i, j int) bool {
-		return sorted[i].Key < sorted[j].Key
-	})
-
 	dataKeyInfos := make([]interface{}, len(keyInfos.Keys))
 
-	for idx, keyInfo := range sorted {
+	for idx, keyInfo := range keyInfos.Keys {
 		dataKeyInfo := make(map[string]interface{})
 
 		dataKeyInfo["key"] = keyInfo.Key
 		dataKeyInfo["value"] = keyInfo.Value
+		dataKeyInfo["decoded"] = "null"
+		if !opts.KeysOnly {
+			decoded, decodeErr := DecodeKeyValue(keyInfo.Key, keyInfo.Value, valueFormat)
+			if decodeErr != nil {
+				return decodeErr
+			}
+			dataKeyInfo["decoded"] = decoded
+		}
 		dataKeyInfo["version"] = keyInfo.Version
 		dataKeyInfo["create_revision"] = keyInfo.CreateRevision
 		dataKeyInfo["mod_revision"] = keyInfo.ModRevision
@@ -108,6 +188,8 @@ func dataSourceKeyRangeRead(d *schema.ResourceData, meta interface{}) error {
 	}
 
 	d.Set("results", dataKeyInfos)
+	d.Set("keys_count", keyInfos.Count)
+	d.Set("header_revision", keyInfos.Revision)
 
 	return nil
 }
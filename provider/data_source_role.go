@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func dataSourceRole() *schema.Resource {
+	permissions := computedSchemaFromResourceSchema(resourceRole().Schema["permissions"])
+	permissions.Description = "Permissions granted to the role on various etcd key ranges."
+
+	return &schema.Resource{
+		Description: "Retrieves information about a role.",
+		Read:        dataSourceRoleRead,
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Description:  "Name of the role to retrieve.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"must_exist": &schema.Schema{
+				Description: "Whether to cause an error if the role is not found.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+			},
+			"permissions":      permissions,
+			"effective_ranges": computedSchemaFromResourceSchema(resourceRole().Schema["effective_ranges"]),
+			"found": &schema.Schema{
+				Description: "Whether the role was found.",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func dataSourceRoleRead(d *schema.ResourceData, meta interface{}) error {
+	cli := meta.(*client.EtcdClient)
+	name := d.Get("name").(string)
+	mustExist := d.Get("must_exist").(bool)
+
+	d.SetId(name)
+
+	resPermissions, roleExists, err := cli.GetRolePermissions(name)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error retrieving role '%s': %s", name, err.Error()))
+	}
+
+	if !roleExists {
+		if mustExist {
+			return errors.New(fmt.Sprintf("Error retrieving role '%s': it was not found", name))
+		}
+
+		d.Set("found", false)
+		return nil
+	}
+
+	d.Set("permissions", rolePermissionsToSchema(resPermissions, []client.EtcdRolePermission{}))
+	d.Set("effective_ranges", roleEffectiveRangesToSchema(resPermissions))
+	d.Set("found", true)
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func dataSourceRoles() *schema.Resource {
+	return &schema.Resource{
+		Description: "Retrieves the names of all the roles in etcd.",
+		Read:        dataSourceRolesRead,
+		Schema: map[string]*schema.Schema{
+			"names": &schema.Schema{
+				Description: "Names of the roles, sorted alphabetically.",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+func dataSourceRolesRead(d *schema.ResourceData, meta interface{}) error {
+	cli := meta.(*client.EtcdClient)
+
+	d.SetId("roles")
+
+	names, err := cli.ListRoles()
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error retrieving roles list: %s", err.Error()))
+	}
+	sort.Strings(names)
+
+	d.Set("names", names)
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package provider
+
+import (
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+/*
+Returns a copy of a resource schema attribute where the attribute and its nested attributes are computed.
+Used to expose the same attributes in data sources as in the corresponding resources.
+*/
+func computedSchemaFromResourceSchema(resourceSchema *schema.Schema) *schema.Schema {
+	computed := &schema.Schema{
+		Description: resourceSchema.Description,
+		Type:        resourceSchema.Type,
+		Sensitive:   resourceSchema.Sensitive,
+		Computed:    true,
+	}
+
+	switch elem := resourceSchema.Elem.(type) {
+	case *schema.Resource:
+		nested := make(map[string]*schema.Schema)
+		for key, val := range elem.Schema {
+			nested[key] = computedSchemaFromResourceSchema(val)
+		}
+		computed.Elem = &schema.Resource{Schema: nested}
+	case *schema.Schema:
+		computed.Elem = &schema.Schema{Type: elem.Type}
+	}
+
+	return computed
+}
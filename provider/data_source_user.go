@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func dataSourceUser() *schema.Resource {
+	return &schema.Resource{
+		Description: "Retrieves information about a user.",
+		Read:        dataSourceUserRead,
+		Schema: map[string]*schema.Schema{
+			"username": {
+				Description:  "Name of the user to retrieve.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"must_exist": &schema.Schema{
+				Description: "Whether to cause an error if the user is not found.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+			},
+			"detect_auth_mode": &schema.Schema{
+				Description: "Whether to detect the authentication mode of the user. As etcd only reports it through authentication attempts, this attempts to authenticate as the user with an empty password whenever the data source is read.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"roles": computedSchemaFromResourceSchema(resourceUser().Schema["roles"]),
+			"auth_mode": &schema.Schema{
+				Description: "Authentication mode of the user. Can be: password (the user was created with a password, possibly blank), no-password (the user can only authenticate with a tls certificate) or unknown (detect_auth_mode is not set, authentication is not enabled on the etcd cluster or the authentication attempt failed unexpectedly).",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"found": &schema.Schema{
+				Description: "Whether the user was found.",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func dataSourceUserRead(d *schema.ResourceData, meta interface{}) error {
+	cli := meta.(*client.EtcdClient)
+	username := d.Get("username").(string)
+	mustExist := d.Get("must_exist").(bool)
+
+	d.SetId(username)
+
+	resRoles, userExists, err := cli.GetUserRoles(username)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error retrieving user '%s': %s", username, err.Error()))
+	}
+
+	if !userExists {
+		if mustExist {
+			return errors.New(fmt.Sprintf("Error retrieving user '%s': it was not found", username))
+		}
+
+		d.Set("found", false)
+		return nil
+	}
+
+	authMode := "unknown"
+	if d.Get("detect_auth_mode").(bool) {
+		authMode = getUserAuthMode(cli, username)
+	}
+
+	d.Set("roles", resRoles)
+	d.Set("auth_mode", authMode)
+	d.Set("found", true)
+
+	return nil
+}
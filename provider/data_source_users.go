@@ -0,0 +1,43 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func dataSourceUsers() *schema.Resource {
+	return &schema.Resource{
+		Description: "Retrieves the names of all the users in etcd.",
+		Read:        dataSourceUsersRead,
+		Schema: map[string]*schema.Schema{
+			"usernames": &schema.Schema{
+				Description: "Names of the users, sorted alphabetically.",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+func dataSourceUsersRead(d *schema.ResourceData, meta interface{}) error {
+	cli := meta.(*client.EtcdClient)
+
+	d.SetId("users")
+
+	usernames, err := cli.ListUsers()
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error retrieving users list: %s", err.Error()))
+	}
+	sort.Strings(usernames)
+
+	d.Set("usernames", usernames)
+
+	return nil
+}
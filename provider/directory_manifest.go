@@ -0,0 +1,241 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+/*
+Sha256 hashes of the synchronized files, indexed by their path relative to the directory (or key relative to the prefix).
+The revision is the revision of the etcd store at which the hashes were known to match the content of the key prefix.
+The hashes are of the content of the files, before it is compressed with the compression of the manifest.
+*/
+type DirectoryManifest struct {
+	Revision    int64
+	Compression string
+	Files       map[string]string
+}
+
+/*
+Content of a key prefix, indexed by key relative to the prefix, with the values decompressed.
+Values that were not stored with the expected compression are listed so that they can be stored again.
+*/
+type KeyPrefixContent struct {
+	Values       map[string]string
+	Uncompressed []string
+}
+
+/*
+Manifest of a key prefix whose changes were only partially applied, at the revision of the last applied changes.
+As it lists no files, it is never valid for the key prefix and the next synchronization retrieves the whole content of the prefix.
+*/
+func PartialManifest(revision int64, compression string) DirectoryManifest {
+	return DirectoryManifest{Revision: revision, Compression: compression}
+}
+
+func HashContent(content []byte) string {
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+/*
+A manifest still describes the key prefix if no key of the prefix was added or modified after its revision
+and no key was deleted, in which case the prefix would have less keys than the manifest.
+The values of the prefix must also be stored with the expected compression.
+*/
+func (manifest DirectoryManifest) IsValidFor(fingerprint KeyRangeFingerprint, compression string) bool {
+	return manifest.Files != nil && manifest.Compression == compression && fingerprint.MaxModRevision <= manifest.Revision && fingerprint.KeysCount == int64(len(manifest.Files))
+}
+
+func getCompanionManifest(cli *client.EtcdClient, manifestKey string) (DirectoryManifest, bool, error) {
+	info, err := cli.GetKey(manifestKey, client.GetKeyOptions{})
+	if err != nil {
+		return DirectoryManifest{}, false, errors.New(fmt.Sprintf("Error retrieving manifest key %s: %s", manifestKey, err.Error()))
+	}
+
+	if !info.Found() {
+		return DirectoryManifest{}, false, nil
+	}
+
+	//A manifest that cannot be parsed is simply rebuilt from the content of the key prefix
+	var manifest DirectoryManifest
+	if err := json.Unmarshal([]byte(info.Value), &manifest); err != nil {
+		return DirectoryManifest{}, false, nil
+	}
+
+	return manifest, true, nil
+}
+
+/*
+Returns the manifest of the key prefix without retrieving its content if possible.
+The manifest from the state is used first, then the manifest from the companion key (if any).
+If neither still describes the key prefix, its content is retrieved and returned along with the manifest built from it.
+Otherwise, the returned content is nil.
+*/
+func GetKeyPrefixManifest(cli *client.EtcdClient, prefix string, compression string, manifestKey string, stateManifest DirectoryManifest) (DirectoryManifest, *KeyPrefixContent, error) {
+	fingerprint, err := QueryKeyRangeFingerprint(cli, prefix, clientv3.GetPrefixRangeEnd(prefix))
+	if err != nil {
+		return DirectoryManifest{}, nil, errors.New(fmt.Sprintf("Error getting fingerprint of prefix %s: %s", prefix, err.Error()))
+	}
+
+	if stateManifest.IsValidFor(fingerprint, compression) {
+		return stateManifest, nil, nil
+	}
+
+	if manifestKey != "" {
+		companionManifest, found, err := getCompanionManifest(cli, manifestKey)
+		if err != nil {
+			return DirectoryManifest{}, nil, err
+		}
+
+		if found && companionManifest.IsValidFor(fingerprint, compression) {
+			return companionManifest, nil, nil
+		}
+	}
+
+	return GetKeyPrefixContentManifest(cli, prefix, compression)
+}
+
+func GetKeyPrefixContentManifest(cli *client.EtcdClient, prefix string, compression string) (DirectoryManifest, *KeyPrefixContent, error) {
+	prefixKeys, err := cli.GetPrefix(prefix)
+	if err != nil {
+		return DirectoryManifest{}, nil, errors.New(fmt.Sprintf("Error retrieving content of prefix %s: %s", prefix, err.Error()))
+	}
+
+	content := KeyPrefixContent{Values: make(map[string]string), Uncompressed: []string{}}
+	manifest := DirectoryManifest{Revision: prefixKeys.Revision, Compression: compression, Files: make(map[string]string)}
+	for key, value := range prefixKeys.Keys.ToValueMap(prefix) {
+		decompressed, compressed, err := DecompressValue(value, compression)
+		if err != nil {
+			return DirectoryManifest{}, nil, errors.New(fmt.Sprintf("Error decompressing value of key %s: %s", prefix+key, err.Error()))
+		}
+
+		if !compressed {
+			content.Uncompressed = append(content.Uncompressed, key)
+		}
+		content.Values[key] = decompressed
+		manifest.Files[key] = HashContent([]byte(decompressed))
+	}
+
+	return manifest, &content, nil
+}
+
+func PutCompanionManifest(cli *client.EtcdClient, manifestKey string, manifest DirectoryManifest) error {
+	value, _ := json.Marshal(manifest)
+	_, err := cli.PutKey(manifestKey, string(value))
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error storing manifest key %s: %s", manifestKey, err.Error()))
+	}
+
+	return nil
+}
+
+/*
+Retrieves the decompressed values of the given keys of the prefix, as long as they still match the hashes of the manifest.
+Returns false if one of them does not, in which case the whole content of the prefix should be retrieved instead.
+*/
+func GetKeyPrefixValues(cli *client.EtcdClient, prefix string, keys []string, manifest DirectoryManifest) (map[string]string, bool, error) {
+	values := make(map[string]string)
+	for _, key := range keys {
+		info, err := cli.GetKey(prefix+key, client.GetKeyOptions{})
+		if err != nil {
+			return nil, false, errors.New(fmt.Sprintf("Error retrieving key %s: %s", prefix+key, err.Error()))
+		}
+
+		if !info.Found() {
+			return nil, false, nil
+		}
+
+		value, _, err := DecompressValue(info.Value, manifest.Compression)
+		if err != nil {
+			return nil, false, errors.New(fmt.Sprintf("Error decompressing value of key %s: %s", prefix+key, err.Error()))
+		}
+
+		if HashContent([]byte(value)) != manifest.Files[key] {
+			return nil, false, nil
+		}
+		values[key] = value
+	}
+
+	return values, true, nil
+}
+
+//Etcd rejects transactions with more operations than its --max-txn-ops option, which defaults to 128
+const maxTxnOps = 128
+
+//Size under etcd's default --max-request-bytes option of 1.5MiB, leaving headroom for the keys and the encoding of the request
+const maxTxnBytes = 1048576
+
+/*
+Splits the changes in batches that can each be applied in a transaction.
+A batch holds at most maxTxnOps operations and, unless it holds a single operation, at most maxBytes bytes of keys and values.
+The keys found in the leases map are attached to their lease when they are put.
+*/
+func batchDiffOps(prefix string, diff client.KeyDiff, leases map[string]clientv3.LeaseID, maxBytes int64) [][]clientv3.Op {
+	batches := [][]clientv3.Op{}
+	batch := []clientv3.Op{}
+	batchBytes := int64(0)
+
+	addOp := func(op clientv3.Op, size int64) {
+		if len(batch) > 0 && (len(batch) >= maxTxnOps || batchBytes+size > maxBytes) {
+			batches = append(batches, batch)
+			batch = []clientv3.Op{}
+			batchBytes = 0
+		}
+		batch = append(batch, op)
+		batchBytes += size
+	}
+
+	for _, key := range diff.Deletions {
+		addOp(clientv3.OpDelete(prefix+key), int64(len(prefix+key)))
+	}
+	for _, changes := range []map[string]string{diff.Inserts, diff.Updates} {
+		for key, val := range changes {
+			opts := []clientv3.OpOption{}
+			if lease, leased := leases[key]; leased {
+				opts = append(opts, clientv3.WithLease(lease))
+			}
+			addOp(clientv3.OpPut(prefix+key, val, opts...), int64(len(prefix+key)+len(val)))
+		}
+	}
+
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+
+	return batches
+}
+
+/*
+Applies the changes to the key prefix, provided that no key of the prefix was added or modified after the given revision.
+This guarantees that the keys that are not changed still match the manifest the changes were computed from.
+Changes that do not fit in a single transaction are applied in several, each one conditional on no other change since the previous one.
+Therefore, the changes are not applied atomically if there are several transactions.
+Returns the revision of the store after the changes, which is the modification revision of the last changed keys, and whether they were all applied.
+If they were not, the returned revision is the revision after the last transaction that was applied, or 0 if none were.
+*/
+func ApplyDiffToPrefixIfUnchanged(cli *client.EtcdClient, prefix string, diff client.KeyDiff, revision int64, maxBytes int64) (int64, bool, error) {
+	appliedRevision := int64(0)
+	for _, ops := range batchDiffOps(prefix, diff, nil, maxBytes) {
+		cmp := clientv3.Compare(clientv3.ModRevision(prefix), "<", revision+1).WithRange(clientv3.GetPrefixRangeEnd(prefix))
+		res, err := commitTxnWithRetries(cli, []clientv3.Cmp{cmp}, ops, cli.Retries)
+		if err != nil {
+			return appliedRevision, false, err
+		}
+
+		if !res.Succeeded {
+			return appliedRevision, false, nil
+		}
+		revision = res.Header.Revision
+		appliedRevision = revision
+	}
+
+	return revision, true, nil
+}
@@ -0,0 +1,134 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+	"time"
+)
+
+//Name, relative to the key prefix, of the key where the metadata of the synchronized files is stored
+const synchronizedDirectoryMetadataKey = ".etcd-sync-metadata"
+
+/*
+Metadata of a file that can be preserved when it is synchronized.
+Fields that are not preserved are left at their zero value so that they do not cause differences.
+Only the ownership of symbolic links is preserved as their mode and mtime cannot be changed without changing their target.
+*/
+type FileMetadata struct {
+	Mode  uint32
+	Uid   int
+	Gid   int
+	Mtime int64
+}
+
+type FileMetadataFields struct {
+	Mode      bool
+	Ownership bool
+	Mtime     bool
+}
+
+func (fields FileMetadataFields) Enabled() bool {
+	return fields.Mode || fields.Ownership || fields.Mtime
+}
+
+func (fields FileMetadataFields) fromFileInfo(info fs.FileInfo) FileMetadata {
+	metadata := FileMetadata{}
+	isLink := info.Mode()&fs.ModeSymlink != 0
+
+	if fields.Mode && !isLink {
+		metadata.Mode = uint32(info.Mode().Perm())
+	}
+
+	if fields.Ownership {
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			metadata.Uid = int(stat.Uid)
+			metadata.Gid = int(stat.Gid)
+		}
+	}
+
+	if fields.Mtime && !isLink {
+		metadata.Mtime = info.ModTime().UnixNano()
+	}
+
+	return metadata
+}
+
+//Returns the metadata of each file in the directory, indexed by its path relative to the directory
+func GetDirectoryMetadata(path string, fields FileMetadataFields, symlinks string) (map[string]FileMetadata, error) {
+	metadata := make(map[string]FileMetadata)
+
+	err := WalkDirectoryFiles(path, symlinks, func(file DirectoryFile) error {
+		metadata[file.Path] = fields.fromFileInfo(file.Info)
+		return nil
+	})
+
+	return metadata, err
+}
+
+/*
+Applies the metadata to the files of the directory.
+Ownership is applied before the mode as changing the owner of a file can clear some of its mode bits.
+Files that are not in the directory are ignored.
+*/
+func ApplyDirectoryMetadata(path string, metadata map[string]FileMetadata, fields FileMetadataFields, symlinks string) error {
+	for file, fileMetadata := range metadata {
+		fPath, err := GetConfinedPath(path, file, symlinks)
+		if err != nil {
+			return err
+		}
+
+		info, err := os.Lstat(fPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		isLink := info.Mode()&fs.ModeSymlink != 0
+
+		if fields.Ownership {
+			if err := os.Lchown(fPath, fileMetadata.Uid, fileMetadata.Gid); err != nil {
+				return err
+			}
+		}
+
+		if fields.Mode && !isLink {
+			if err := os.Chmod(fPath, os.FileMode(fileMetadata.Mode)); err != nil {
+				return err
+			}
+		}
+
+		if fields.Mtime && !isLink {
+			mtime := time.Unix(0, fileMetadata.Mtime)
+			if err := os.Chtimes(fPath, mtime, mtime); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+//Maps are serialized with sorted keys so the result is deterministic
+func SerializeDirectoryMetadata(metadata map[string]FileMetadata) string {
+	out, _ := json.Marshal(metadata)
+	return string(out)
+}
+
+func DeserializeDirectoryMetadata(value string) (map[string]FileMetadata, error) {
+	metadata := make(map[string]FileMetadata)
+	if value == "" {
+		return metadata, nil
+	}
+
+	err := json.Unmarshal([]byte(value), &metadata)
+	if err != nil {
+		return metadata, errors.New(fmt.Sprintf("Error parsing files metadata: %s", err.Error()))
+	}
+
+	return metadata, nil
+}
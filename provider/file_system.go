@@ -1,10 +1,16 @@
 package provider
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"io/fs"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
 )
@@ -24,45 +30,401 @@ func EnsureDirectoryExists(path string, dirPermission int32) error {
 	return nil
 }
 
-func GetDirectoryContent(path string) (map[string]client.KeyInfo, error) {
-	keys := make(map[string]client.KeyInfo)
+//Prefix marking values that are the target of a symbolic link rather than the content of a file
+const symlinkValuePrefix = "\x00symlink:"
 
-	err := filepath.WalkDir(path, func(path string, entry fs.DirEntry, err error) error {
+func validateSymlinks(val interface{}, key string) (warns []string, errs []error) {
+	symlinks := val.(string)
+	if symlinks != "skip" && symlinks != "follow" && symlinks != "preserve" {
+		return []string{}, []error{errors.New("The symlinks field must be one of the following: skip, follow, preserve")}
+	}
+	return []string{}, []error{}
+}
+
+/*
+File found in a directory, with its path relative to the directory.
+If the file is a symbolic link that is preserved, the target of the link is also provided and the information is about the link itself.
+*/
+type DirectoryFile struct {
+	Path    string
+	AbsPath string
+	Info    fs.FileInfo
+	Target  string
+}
+
+func isSameOrParentDirectory(parent string, path string) bool {
+	return path == parent || strings.HasPrefix(path, strings.TrimSuffix(parent, "/")+"/")
+}
+
+func walkDirectoryFiles(root string, relRoot string, symlinks string, followed []string, fn func(file DirectoryFile) error) error {
+	return filepath.WalkDir(root, func(fPath string, entry fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
-		if !entry.IsDir() {
-			content, err := ioutil.ReadFile(path)
+		if entry.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, fPath)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(filepath.Join(relRoot, relPath))
+
+		if entry.Type()&fs.ModeSymlink == 0 {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			return fn(DirectoryFile{Path: relPath, AbsPath: fPath, Info: info})
+		}
+
+		switch symlinks {
+		case "skip":
+			return nil
+		case "preserve":
+			target, err := os.Readlink(fPath)
 			if err != nil {
 				return err
 			}
 
-			keys[path] = client.KeyInfo{
-				Key:            path,
-				Value:          string(content),
-				Version:        0,
-				CreateRevision: 0,
-				ModRevision:    0,
-				Lease:          0,
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			return fn(DirectoryFile{Path: relPath, AbsPath: fPath, Info: info, Target: target})
+		}
+
+		info, err := os.Stat(fPath)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error following symbolic link %s: %s", fPath, err.Error()))
+		}
+
+		if !info.IsDir() {
+			return fn(DirectoryFile{Path: relPath, AbsPath: fPath, Info: info})
+		}
+
+		target, err := filepath.EvalSymlinks(fPath)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error following symbolic link %s: %s", fPath, err.Error()))
+		}
+
+		parent, err := filepath.EvalSymlinks(filepath.Dir(fPath))
+		if err != nil {
+			return err
+		}
+
+		for _, dir := range append(followed, parent) {
+			if isSameOrParentDirectory(target, dir) {
+				return errors.New(fmt.Sprintf("Symbolic link %s to directory %s creates a cycle", fPath, target))
 			}
 		}
 
+		return walkDirectoryFiles(target, relPath, symlinks, append(followed, target), fn)
+	})
+}
+
+/*
+Calls the function on each file of the directory, handling symbolic links as specified (skip, follow or preserve).
+Links to directories that are followed are walked as if they were directories, unless they create a cycle.
+*/
+func WalkDirectoryFiles(path string, symlinks string, fn func(file DirectoryFile) error) error {
+	root, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+
+	return walkDirectoryFiles(path, "", symlinks, []string{root}, fn)
+}
+
+/*
+Returns the sha256 hash of each file in the directory, indexed by its path relative to the directory.
+The files are hashed as they are read so that their content is not kept in memory.
+Preserved symbolic links are hashed as their marked target.
+*/
+func GetDirectoryHashes(path string, symlinks string) (map[string]string, error) {
+	hashes := make(map[string]string)
+
+	err := WalkDirectoryFiles(path, symlinks, func(file DirectoryFile) error {
+		if file.Target != "" {
+			hashes[file.Path] = HashContent([]byte(symlinkValuePrefix + file.Target))
+			return nil
+		}
+
+		f, err := os.Open(file.AbsPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		hash := sha256.New()
+		if _, err := io.Copy(hash, f); err != nil {
+			return err
+		}
+
+		hashes[file.Path] = hex.EncodeToString(hash.Sum(nil))
 		return nil
 	})
 
-	return keys, err
+	return hashes, err
 }
 
-func applyFileToDirectory(path string, file string, content string, filesPermission int32, dirPermission int32) error {
-	fPath := filepath.Join(path, file)
+/*
+Reads the content of the given files, specified by their path relative to the directory.
+If symbolic links are preserved, the marked target of the links is returned instead of their content.
+*/
+func ReadDirectoryFiles(path string, files []string, symlinks string) (map[string]string, error) {
+	content := make(map[string]string)
+
+	for _, file := range files {
+		fPath := filepath.Join(path, file)
+
+		if symlinks == "preserve" {
+			info, err := os.Lstat(fPath)
+			if err != nil {
+				return content, err
+			}
+
+			if info.Mode()&fs.ModeSymlink != 0 {
+				target, err := os.Readlink(fPath)
+				if err != nil {
+					return content, err
+				}
+
+				content[file] = symlinkValuePrefix + target
+				continue
+			}
+		}
+
+		fContent, err := ioutil.ReadFile(fPath)
+		if err != nil {
+			return content, err
+		}
+
+		content[file] = string(fContent)
+	}
+
+	return content, nil
+}
+
+/*
+Returns the files of the directory whose size once stored with the given compression exceeds the maximum size, along with that size.
+Only the files that exceed the maximum size before compression are compressed to check their compressed size.
+*/
+func GetOversizedFiles(path string, maxSize int64, compression string, symlinks string) (map[string]int64, error) {
+	oversized := make(map[string]int64)
+
+	err := WalkDirectoryFiles(path, symlinks, func(file DirectoryFile) error {
+		if file.Target != "" || file.Info.Size() <= maxSize {
+			return nil
+		}
+
+		size := file.Info.Size()
+		if compression != "none" {
+			content, err := ioutil.ReadFile(file.AbsPath)
+			if err != nil {
+				return err
+			}
+
+			compressed, err := CompressValue(string(content), compression)
+			if err != nil {
+				return err
+			}
+
+			size = int64(len(compressed))
+			if size <= maxSize {
+				return nil
+			}
+		}
+
+		oversized[file.Path] = size
+		return nil
+	})
+
+	return oversized, err
+}
+
+type DirectoryWriteOptions struct {
+	FilesPermission       int32
+	DirectoryPermission   int32
+	Symlinks              string
+	PruneEmptyDirectories bool
+}
+
+/*
+Resolves the symbolic links of a path whose end may not exist yet.
+The deepest existing parent of the path is resolved and the rest of the path is appended to it.
+*/
+func resolveExistingPath(path string) (string, error) {
+	remainder := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(resolved, remainder), nil
+		}
+
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		//A link to a missing target exists but cannot be resolved
+		if _, lstatErr := os.Lstat(path); lstatErr == nil {
+			return "", errors.New(fmt.Sprintf("Symbolic link %s has a missing target", path))
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		remainder = filepath.Join(filepath.Base(path), remainder)
+		path = parent
+	}
+}
+
+//Keys that are not clean paths (ex: a/../b or a//b) are rejected rather than normalized, as several keys would otherwise map to the same file
+func checkCleanFilePath(path string, file string) error {
+	if filepath.Clean(file) != file {
+		return errors.New(fmt.Sprintf("Key %s (relative to the key prefix) is not a clean path and cannot be mapped to a file of directory %s", file, path))
+	}
+
+	return nil
+}
+
+/*
+Returns the path of a file of the directory, making sure that it stays within the directory.
+Whatever the handling of symbolic links, the resolved path of the parent directory of the file must be within the resolved directory
+and so must the resolved path of the file itself if links are followed, as the file is then written through.
+Unless symbolic links are followed, the parent directories of the file within the directory must not be links either.
+The file must be a clean path.
+*/
+func GetConfinedPath(path string, file string, symlinks string) (string, error) {
+	err := checkCleanFilePath(path, file)
+	if err != nil {
+		return "", err
+	}
+
+	root := filepath.Clean(path)
+	fPath := filepath.Join(root, file)
+
+	relPath, err := filepath.Rel(root, fPath)
+	if err != nil || relPath == "." || relPath == ".." || strings.HasPrefix(relPath, "../") {
+		return "", errors.New(fmt.Sprintf("File %s is outside of directory %s", file, path))
+	}
+
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+
+	target := filepath.Dir(fPath)
+	if symlinks == "follow" {
+		target = fPath
+	}
+
+	resolved, err := resolveExistingPath(target)
+	if err != nil {
+		return "", err
+	}
+
+	if !isSameOrParentDirectory(resolvedRoot, resolved) {
+		return "", errors.New(fmt.Sprintf("File %s resolves to %s which is outside of directory %s", file, resolved, path))
+	}
+
+	if symlinks != "follow" {
+		dir := root
+		components := strings.Split(relPath, "/")
+		for _, component := range components[:len(components)-1] {
+			dir = filepath.Join(dir, component)
+			info, err := os.Lstat(dir)
+			if err != nil {
+				if os.IsNotExist(err) {
+					break
+				}
+				return "", err
+			}
+
+			if info.Mode()&fs.ModeSymlink != 0 {
+				return "", errors.New(fmt.Sprintf("File %s is under symbolic link %s of directory %s", file, dir, path))
+			}
+		}
+	}
+
+	return fPath, nil
+}
+
+/*
+Removes the parent directories of a deleted file that are left empty, up to the root directory which is kept.
+Symbolic links to directories are never removed, even if the directory they link to is empty.
+*/
+func pruneEmptyDirectories(path string, fPath string) error {
+	root := filepath.Clean(path)
+	for dir := filepath.Dir(fPath); dir != root && isSameOrParentDirectory(root, dir); dir = filepath.Dir(dir) {
+		info, err := os.Lstat(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+
+		if info.Mode()&fs.ModeSymlink != 0 || !info.IsDir() {
+			return nil
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+
+		if len(entries) > 0 {
+			return nil
+		}
+
+		if err := os.Remove(dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+Writes the content of a file in the directory, creating its parent directories if needed.
+Marked symbolic link targets are written as links if symbolic links are preserved.
+Unless symbolic links are followed, a link found where the file is to be written is replaced rather than written through.
+*/
+func applyFileToDirectory(path string, file string, content string, opts DirectoryWriteOptions) error {
+	fPath, err := GetConfinedPath(path, file, opts.Symlinks)
+	if err != nil {
+		return err
+	}
+
 	fdir := filepath.Dir(fPath)
-	mkdirErr := os.MkdirAll(fdir, os.FileMode(dirPermission))
+	mkdirErr := os.MkdirAll(fdir, os.FileMode(opts.DirectoryPermission))
 	if mkdirErr != nil {
 		return mkdirErr
 	}
 
-	f, err := os.OpenFile(fPath, os.O_RDWR|os.O_CREATE, os.FileMode(filesPermission))
+	isLink := opts.Symlinks == "preserve" && strings.HasPrefix(content, symlinkValuePrefix)
+	if isLink || opts.Symlinks != "follow" {
+		info, err := os.Lstat(fPath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		if err == nil && (isLink || info.Mode()&fs.ModeSymlink != 0) {
+			if err := os.Remove(fPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	if isLink {
+		return os.Symlink(strings.TrimPrefix(content, symlinkValuePrefix), fPath)
+	}
+
+	f, err := os.OpenFile(fPath, os.O_RDWR|os.O_CREATE, os.FileMode(opts.FilesPermission))
 	if err != nil {
 		return err
 	}
@@ -86,24 +448,47 @@ func applyFileToDirectory(path string, file string, content string, filesPermiss
 	return nil
 }
 
-func ApplyDiffToDirectory(path string, diff client.KeyDiff, filesPermission int32, dirPermission int32) error {
+/*
+Applies the differential to the files of the directory.
+Files to delete that are already missing are ignored and the directories they leave empty are removed if specified.
+*/
+func ApplyDiffToDirectory(path string, diff client.KeyDiff, opts DirectoryWriteOptions) error {
+	//Keys are checked beforehand so that the directory is not left partially synchronized by an invalid key
+	for _, file := range diffKeys(diff) {
+		err := checkCleanFilePath(path, file)
+		if err != nil {
+			return err
+		}
+	}
+
 	for _, file := range diff.Deletions {
-		fPath := filepath.Join(path, file)
-		err := os.Remove(fPath)
+		fPath, err := GetConfinedPath(path, file, opts.Symlinks)
 		if err != nil {
 			return err
 		}
+
+		err = os.Remove(fPath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		if opts.PruneEmptyDirectories {
+			err := pruneEmptyDirectories(path, fPath)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	for file, content := range diff.Inserts {
-		applyErr := applyFileToDirectory(path, file, content, filesPermission, dirPermission)
+		applyErr := applyFileToDirectory(path, file, content, opts)
 		if applyErr != nil {
 			return applyErr
 		}
 	}
 
 	for file, content := range diff.Updates {
-		applyErr := applyFileToDirectory(path, file, content, filesPermission, dirPermission)
+		applyErr := applyFileToDirectory(path, file, content, opts)
 		if applyErr != nil {
 			return applyErr
 		}
@@ -0,0 +1,202 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
+	yaml "gopkg.in/yaml.v3"
+)
+
+/*
+Entry of a key range file. The value is base64 encoded so that binary values can be represented.
+A lease ttl (in seconds) can optionally be specified to attach the key to a lease when it is imported.
+The remaining fields are informative and only populated when a key range is exported.
+*/
+type KeyRangeFileEntry struct {
+	Key            string `json:"key" yaml:"key"`
+	Value          string `json:"value" yaml:"value"`
+	LeaseTtl       int64  `json:"lease_ttl,omitempty" yaml:"lease_ttl,omitempty"`
+	Version        int64  `json:"version,omitempty" yaml:"version,omitempty"`
+	CreateRevision int64  `json:"create_revision,omitempty" yaml:"create_revision,omitempty"`
+	ModRevision    int64  `json:"mod_revision,omitempty" yaml:"mod_revision,omitempty"`
+	Lease          int64  `json:"lease,omitempty" yaml:"lease,omitempty"`
+}
+
+func (entry KeyRangeFileEntry) DecodedValue() (string, error) {
+	value, err := base64.StdEncoding.DecodeString(entry.Value)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("Value of key '%s' is not valid base64: %s", entry.Key, err.Error()))
+	}
+
+	return string(value), nil
+}
+
+func keyIsInRange(key string, rangeStart string, rangeEnd string) bool {
+	if rangeStart == rangeEnd {
+		return key == rangeStart
+	}
+
+	return key >= rangeStart && (rangeEnd == "\x00" || key < rangeEnd)
+}
+
+func parseJsonKeyRangeFile(content []byte) ([]KeyRangeFileEntry, error) {
+	entries := []KeyRangeFileEntry{}
+
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&entries)
+	if err != nil {
+		return entries, err
+	}
+
+	return entries, nil
+}
+
+func parseJsonlKeyRangeFile(content []byte) ([]KeyRangeFileEntry, error) {
+	entries := []KeyRangeFileEntry{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		entry := KeyRangeFileEntry{}
+		dec := json.NewDecoder(strings.NewReader(line))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&entry)
+		if err != nil {
+			return entries, errors.New(fmt.Sprintf("Line %d: %s", lineNumber, err.Error()))
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+/*
+Reads a key range file in either the json format (an array of entries) or the jsonl format (one entry per line).
+Returns the keys in the file with their decoded values and lease ttls.
+All the keys are expected to be contained in the range defined by rangeStart and rangeEnd.
+*/
+func ReadKeyRangeFile(path string, format string, rangeStart string, rangeEnd string) (map[string]string, map[string]int64, error) {
+	values := make(map[string]string)
+	leaseTtls := make(map[string]int64)
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return values, leaseTtls, err
+	}
+
+	var entries []KeyRangeFileEntry
+	if format == "jsonl" {
+		entries, err = parseJsonlKeyRangeFile(content)
+	} else {
+		entries, err = parseJsonKeyRangeFile(content)
+	}
+	if err != nil {
+		return values, leaseTtls, errors.New(fmt.Sprintf("File '%s' is not a valid %s key range file: %s", path, format, err.Error()))
+	}
+
+	for _, entry := range entries {
+		if entry.Key == "" {
+			return values, leaseTtls, errors.New(fmt.Sprintf("File '%s' contains an entry with an empty key", path))
+		}
+
+		if !keyIsInRange(entry.Key, rangeStart, rangeEnd) {
+			return values, leaseTtls, errors.New(fmt.Sprintf("Key '%s' in file '%s' is outside the range ['%s', '%s')", entry.Key, path, rangeStart, rangeEnd))
+		}
+
+		if _, ok := values[entry.Key]; ok {
+			return values, leaseTtls, errors.New(fmt.Sprintf("Key '%s' is defined more than once in file '%s'", entry.Key, path))
+		}
+
+		if entry.LeaseTtl < 0 {
+			return values, leaseTtls, errors.New(fmt.Sprintf("Key '%s' in file '%s' has a negative lease ttl", entry.Key, path))
+		}
+
+		value, valueErr := entry.DecodedValue()
+		if valueErr != nil {
+			return values, leaseTtls, errors.New(fmt.Sprintf("Error in file '%s': %s", path, valueErr.Error()))
+		}
+
+		values[entry.Key] = value
+		if entry.LeaseTtl > 0 {
+			leaseTtls[entry.Key] = entry.LeaseTtl
+		}
+	}
+
+	return values, leaseTtls, nil
+}
+
+func SortKeyInfos(keyInfos client.KeyInfoMap) []client.KeyInfo {
+	sorted := make([]client.KeyInfo, len(keyInfos))
+	idx := 0
+	for _, keyInfo := range keyInfos {
+		sorted[idx] = keyInfo
+		idx++
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Key < sorted[j].Key
+	})
+
+	return sorted
+}
+
+/*
+Renders the keys in the given format (json, jsonl or yaml).
+Keys are sorted so that the output is deterministic for a given set of keys.
+*/
+func RenderKeyRangeFile(keyInfos client.KeyInfoMap, format string) ([]byte, error) {
+	entries := []KeyRangeFileEntry{}
+	for _, keyInfo := range SortKeyInfos(keyInfos) {
+		entries = append(entries, KeyRangeFileEntry{
+			Key:            keyInfo.Key,
+			Value:          base64.StdEncoding.EncodeToString([]byte(keyInfo.Value)),
+			Version:        keyInfo.Version,
+			CreateRevision: keyInfo.CreateRevision,
+			ModRevision:    keyInfo.ModRevision,
+			Lease:          keyInfo.Lease,
+		})
+	}
+
+	if format == "yaml" {
+		return yaml.Marshal(entries)
+	}
+
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+
+	if format == "jsonl" {
+		for _, entry := range entries {
+			err := enc.Encode(entry)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return buf.Bytes(), nil
+	}
+
+	enc.SetIndent("", "  ")
+	err := enc.Encode(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
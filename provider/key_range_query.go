@@ -0,0 +1,177 @@
+package provider
+
+import (
+	"regexp"
+
+	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const keyRangePageSize = 1000
+
+/*
+Options to query a key range.
+A limit of 0 means that all the keys are returned.
+If a key filter is specified, only the keys matching it are returned and counted.
+*/
+type KeyRangeQueryOptions struct {
+	Revision   int64
+	Limit      int64
+	KeysOnly   bool
+	CountOnly  bool
+	Descending bool
+	KeyFilter  *regexp.Regexp
+}
+
+/*
+Result of a key range query.
+Keys are sorted by key in the order specified by the query options.
+Count is the total number of keys in the range that satisfy the key filter, irrespective of the limit.
+Revision is the revision of the store at which the keys were read.
+*/
+type KeyRangeQueryResult struct {
+	Keys     []client.KeyInfo
+	Count    int64
+	Revision int64
+}
+
+func (opts KeyRangeQueryOptions) pageOptions(rangeEnd string, revision int64) []clientv3.OpOption {
+	sortOrder := clientv3.SortAscend
+	if opts.Descending {
+		sortOrder = clientv3.SortDescend
+	}
+
+	pageOpts := []clientv3.OpOption{
+		clientv3.WithRange(rangeEnd),
+		clientv3.WithLimit(keyRangePageSize),
+		clientv3.WithSort(clientv3.SortByKey, sortOrder),
+	}
+
+	if revision > 0 {
+		pageOpts = append(pageOpts, clientv3.WithRev(revision))
+	}
+
+	if opts.KeysOnly || opts.CountOnly {
+		pageOpts = append(pageOpts, clientv3.WithKeysOnly())
+	}
+
+	return pageOpts
+}
+
+func queryKeyRangeCount(cli *client.EtcdClient, key string, rangeEnd string, opts KeyRangeQueryOptions) (KeyRangeQueryResult, error) {
+	countOpts := []clientv3.OpOption{clientv3.WithCountOnly(), clientv3.WithRange(rangeEnd)}
+	if opts.Revision > 0 {
+		countOpts = append(countOpts, clientv3.WithRev(opts.Revision))
+	}
+
+	res, err := getWithRetries(cli, key, countOpts, opts.Revision, cli.Retries)
+	if err != nil {
+		return KeyRangeQueryResult{Keys: []client.KeyInfo{}, Revision: -1}, err
+	}
+
+	readRevision := res.Header.Revision
+	if opts.Revision > 0 {
+		readRevision = opts.Revision
+	}
+
+	return KeyRangeQueryResult{
+		Keys:     []client.KeyInfo{},
+		Count:    res.Count,
+		Revision: readRevision,
+	}, nil
+}
+
+/*
+Retrieves the keys in a range, one page at a time, so that large ranges do not have to be returned in a single response.
+All the pages are read at the same revision of the store so that the result is consistent.
+*/
+func QueryKeyRange(cli *client.EtcdClient, key string, rangeEnd string, opts KeyRangeQueryOptions) (KeyRangeQueryResult, error) {
+	if opts.CountOnly && opts.KeyFilter == nil {
+		return queryKeyRangeCount(cli, key, rangeEnd, opts)
+	}
+
+	result := KeyRangeQueryResult{Keys: []client.KeyInfo{}, Revision: -1}
+	readRevision := opts.Revision
+	pageKey := key
+	pageRangeEnd := rangeEnd
+	for {
+		res, err := getWithRetries(cli, pageKey, opts.pageOptions(pageRangeEnd, readRevision), readRevision, cli.Retries)
+		if err != nil {
+			return KeyRangeQueryResult{Keys: []client.KeyInfo{}, Revision: -1}, err
+		}
+
+		//Pin the remaining pages to the revision of the first one
+		if readRevision <= 0 {
+			readRevision = res.Header.Revision
+		}
+		result.Revision = readRevision
+
+		//Without a filter, etcd already knows how many keys there are in the range
+		if opts.KeyFilter == nil && pageKey == key && pageRangeEnd == rangeEnd {
+			result.Count = res.Count
+		}
+
+		for _, kv := range res.Kvs {
+			if opts.KeyFilter != nil {
+				if !opts.KeyFilter.Match(kv.Key) {
+					continue
+				}
+				result.Count++
+			}
+
+			if opts.CountOnly || (opts.Limit > 0 && int64(len(result.Keys)) >= opts.Limit) {
+				continue
+			}
+
+			result.Keys = append(result.Keys, keyValueToKeyInfo(kv))
+		}
+
+		if !res.More || len(res.Kvs) == 0 {
+			break
+		}
+
+		if opts.Limit > 0 && int64(len(result.Keys)) >= opts.Limit && opts.KeyFilter == nil {
+			break
+		}
+
+		lastKey := string(res.Kvs[len(res.Kvs)-1].Key)
+		if opts.Descending {
+			pageRangeEnd = lastKey
+		} else {
+			pageKey = lastKey + "\x00"
+		}
+	}
+
+	return result, nil
+}
+
+/*
+Cheap fingerprint of a key range that changes whenever a key of the range is added, modified or deleted.
+Any addition or modification increases the highest modification revision and any deletion decreases the number of keys.
+*/
+type KeyRangeFingerprint struct {
+	MaxModRevision int64
+	KeysCount      int64
+}
+
+func QueryKeyRangeFingerprint(cli *client.EtcdClient, key string, rangeEnd string) (KeyRangeFingerprint, error) {
+	opts := []clientv3.OpOption{
+		clientv3.WithRange(rangeEnd),
+		clientv3.WithSort(clientv3.SortByModRevision, clientv3.SortDescend),
+		clientv3.WithLimit(1),
+		clientv3.WithKeysOnly(),
+	}
+
+	res, err := getWithRetries(cli, key, opts, 0, cli.Retries)
+	if err != nil {
+		return KeyRangeFingerprint{}, err
+	}
+
+	fingerprint := KeyRangeFingerprint{KeysCount: res.Count}
+	if len(res.Kvs) > 0 {
+		fingerprint.MaxModRevision = res.Kvs[0].ModRevision
+	}
+
+	return fingerprint, nil
+}
@@ -0,0 +1,134 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
+)
+
+/*
+Key range with an exclusive end, following etcd's conventions:
+an end of "\x00" includes all the keys greater or equal to the key
+and an end equal to the key (or empty) only includes the key.
+*/
+type KeyRange struct {
+	Key      string
+	RangeEnd string
+}
+
+func (keyRange KeyRange) IsSingleKey() bool {
+	return keyRange.RangeEnd == "" || keyRange.RangeEnd == keyRange.Key
+}
+
+func (keyRange KeyRange) IsUnbounded() bool {
+	return keyRange.RangeEnd == "\x00"
+}
+
+// Exclusive end of the range where single keys are expressed as a range ending right after the key
+func (keyRange KeyRange) normalizedEnd() string {
+	if keyRange.IsSingleKey() {
+		return keyRange.Key + "\x00"
+	}
+
+	return keyRange.RangeEnd
+}
+
+func (keyRange KeyRange) Validate() error {
+	if !keyRange.IsUnbounded() && !keyRange.IsSingleKey() && keyRange.RangeEnd < keyRange.Key {
+		return errors.New(fmt.Sprintf("Range end '%s' is lower than key '%s' so the range would not contain any key", keyRange.RangeEnd, keyRange.Key))
+	}
+
+	return nil
+}
+
+// Returns true if the end of the range a is greater or equal to the end of range b
+func endIsAfter(a KeyRange, b KeyRange) bool {
+	if a.IsUnbounded() {
+		return true
+	}
+
+	if b.IsUnbounded() {
+		return false
+	}
+
+	return a.normalizedEnd() >= b.normalizedEnd()
+}
+
+func (keyRange KeyRange) ContainsKey(key string) bool {
+	if key < keyRange.Key {
+		return false
+	}
+
+	return keyRange.IsUnbounded() || key < keyRange.normalizedEnd()
+}
+
+func (keyRange KeyRange) ContainsRange(other KeyRange) bool {
+	return other.Key >= keyRange.Key && endIsAfter(keyRange, other)
+}
+
+/*
+Merges overlapping or adjacent ranges together.
+The result is sorted by key.
+*/
+func MergeKeyRanges(keyRanges []KeyRange) []KeyRange {
+	sorted := make([]KeyRange, len(keyRanges))
+	copy(sorted, keyRanges)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Key < sorted[j].Key
+	})
+
+	merged := []KeyRange{}
+	for _, keyRange := range sorted {
+		if len(merged) == 0 {
+			merged = append(merged, keyRange)
+			continue
+		}
+
+		last := &merged[len(merged)-1]
+		if last.IsUnbounded() || keyRange.Key <= last.normalizedEnd() {
+			if endIsAfter(keyRange, *last) {
+				last.RangeEnd = keyRange.RangeEnd
+				if keyRange.IsSingleKey() {
+					last.RangeEnd = keyRange.normalizedEnd()
+				}
+			}
+			continue
+		}
+
+		merged = append(merged, keyRange)
+	}
+
+	//Ranges that ended up containing a single key are expressed with etcd's single key convention
+	for idx, keyRange := range merged {
+		if keyRange.RangeEnd == keyRange.Key+"\x00" || keyRange.RangeEnd == "" {
+			merged[idx].RangeEnd = keyRange.Key
+		}
+	}
+
+	return merged
+}
+
+/*
+Returns the ranges on which each type of access (read or write) is granted by the permissions, with overlapping ranges merged.
+A readwrite permission grants both types of access.
+*/
+func GetEffectiveRanges(permissions []client.EtcdRolePermission) map[string][]KeyRange {
+	ranges := map[string][]KeyRange{"read": []KeyRange{}, "write": []KeyRange{}}
+	for _, permission := range permissions {
+		keyRange := KeyRange{permission.Key, permission.RangeEnd}
+		if permission.Permission == "read" || permission.Permission == "readwrite" {
+			ranges["read"] = append(ranges["read"], keyRange)
+		}
+		if permission.Permission == "write" || permission.Permission == "readwrite" {
+			ranges["write"] = append(ranges["write"], keyRange)
+		}
+	}
+
+	for access, accessRanges := range ranges {
+		ranges[access] = MergeKeyRanges(accessRanges)
+	}
+
+	return ranges
+}
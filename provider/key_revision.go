@@ -0,0 +1,54 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+/*
+The client's methods do not support all the options of a get request (sorting, limits, count only, etc).
+Requests that need them go through this function instead, which retries them the same way the client does.
+*/
+func getWithRetries(cli *client.EtcdClient, key string, opts []clientv3.OpOption, revision int64, retries uint64) (*clientv3.GetResponse, error) {
+	ctx, cancel := context.WithTimeout(cli.Context, cli.RequestTimeout)
+	defer cancel()
+
+	res, err := cli.Client.Get(ctx, key, opts...)
+	if err != nil {
+		if err == rpctypes.ErrCompacted {
+			return nil, errors.New(fmt.Sprintf("Revision %d has been compacted", revision))
+		}
+
+		if err == rpctypes.ErrFutureRev {
+			return nil, errors.New(fmt.Sprintf("Revision %d is a future revision", revision))
+		}
+
+		if retries == 0 || !client.ErrorIsRetryable(err) {
+			return nil, err
+		}
+
+		time.Sleep(cli.RetryInterval)
+		return getWithRetries(cli, key, opts, revision, retries-1)
+	}
+
+	return res, nil
+}
+
+func keyValueToKeyInfo(kv *mvccpb.KeyValue) client.KeyInfo {
+	return client.KeyInfo{
+		Key:            string(kv.Key),
+		Value:          string(kv.Value),
+		Version:        kv.Version,
+		CreateRevision: kv.CreateRevision,
+		ModRevision:    kv.ModRevision,
+		Lease:          kv.Lease,
+	}
+}
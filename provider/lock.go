@@ -0,0 +1,195 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+/*
+Lock acquired by the provider. The session keeps its lease alive for as long as the provider runs
+so that the lock is held until it is released or the provider exits and the lease expires.
+A persistent lock is not released when the provider shuts down and outlives it until its lease expires,
+unless another run of the provider keeps its lease alive again.
+Its mutex is nil if it was acquired by a previous run of the provider.
+*/
+type HeldLock struct {
+	Name           string
+	Session        *concurrency.Session
+	Mutex          *concurrency.Mutex
+	RequestTimeout time.Duration
+	Persistent     bool
+}
+
+//Locks held by this provider process, indexed by the lease of their session
+var heldLocks = struct {
+	sync.Mutex
+	locks map[clientv3.LeaseID]HeldLock
+}{locks: make(map[clientv3.LeaseID]HeldLock)}
+
+/*
+Acquires the mutex named after the given key prefix, following the same conventions as the etcdctl lock command.
+The session's lease has the given ttl in seconds and the acquisition fails if the lock cannot be acquired within the timeout.
+*/
+func AcquireSessionLock(cli *client.EtcdClient, name string, ttl int, timeout time.Duration, persistent bool) (HeldLock, error) {
+	session, err := concurrency.NewSession(cli.Client, concurrency.WithTTL(ttl), concurrency.WithContext(cli.Context))
+	if err != nil {
+		return HeldLock{}, errors.New(fmt.Sprintf("Error creating session for lock '%s': %s", name, err.Error()))
+	}
+
+	ctx, cancel := context.WithTimeout(cli.Context, timeout)
+	defer cancel()
+
+	mutex := concurrency.NewMutex(session, name)
+	err = mutex.Lock(ctx)
+	if err != nil {
+		session.Close()
+		if ctx.Err() == context.DeadlineExceeded {
+			return HeldLock{}, errors.New(fmt.Sprintf("Timed out after %s waiting for lock '%s'", timeout.String(), name))
+		}
+		return HeldLock{}, errors.New(fmt.Sprintf("Error acquiring lock '%s': %s", name, err.Error()))
+	}
+
+	lock := HeldLock{Name: name, Session: session, Mutex: mutex, RequestTimeout: cli.RequestTimeout, Persistent: persistent}
+
+	heldLocks.Lock()
+	defer heldLocks.Unlock()
+	heldLocks.locks[session.Lease()] = lock
+
+	return lock, nil
+}
+
+func GetSessionLock(leaseId clientv3.LeaseID) (HeldLock, bool) {
+	heldLocks.Lock()
+	defer heldLocks.Unlock()
+	lock, ok := heldLocks.locks[leaseId]
+	return lock, ok
+}
+
+//Whether the provider holds a lock with the given name that is not persistent, which is the lock of the provider
+func IsProviderLock(name string) bool {
+	heldLocks.Lock()
+	defer heldLocks.Unlock()
+	for _, lock := range heldLocks.locks {
+		if lock.Name == name && !lock.Persistent {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+Keeps the lease of a persistent lock acquired by a previous run of the provider alive for as long as the provider runs.
+Returns false if the lease has expired, in which case the lock is no longer held.
+*/
+func ResumePersistentLock(cli *client.EtcdClient, name string, leaseId clientv3.LeaseID) (bool, error) {
+	if _, ok := GetSessionLock(leaseId); ok {
+		return true, nil
+	}
+
+	ctx, cancel := context.WithTimeout(cli.Context, cli.RequestTimeout)
+	defer cancel()
+
+	res, err := cli.Client.TimeToLive(ctx, leaseId)
+	if err != nil {
+		if err == rpctypes.ErrLeaseNotFound {
+			return false, nil
+		}
+		return false, errors.New(fmt.Sprintf("Error retrieving lease of lock '%s': %s", name, err.Error()))
+	}
+
+	if res.TTL <= 0 {
+		return false, nil
+	}
+
+	session, err := concurrency.NewSession(cli.Client, concurrency.WithLease(leaseId), concurrency.WithContext(cli.Context))
+	if err != nil {
+		return false, errors.New(fmt.Sprintf("Error resuming session of lock '%s': %s", name, err.Error()))
+	}
+
+	heldLocks.Lock()
+	defer heldLocks.Unlock()
+	heldLocks.locks[leaseId] = HeldLock{Name: name, Session: session, RequestTimeout: cli.RequestTimeout, Persistent: true}
+
+	return true, nil
+}
+
+/*
+Releases a persistent lock by revoking its lease, which deletes the key of its mutex.
+Unlike the locks of the provider, it may have been acquired by a previous run of the provider.
+*/
+func ReleasePersistentLock(cli *client.EtcdClient, name string, leaseId clientv3.LeaseID) error {
+	heldLocks.Lock()
+	lock, ok := heldLocks.locks[leaseId]
+	delete(heldLocks.locks, leaseId)
+	heldLocks.Unlock()
+
+	if ok {
+		//Stops keeping the lease alive before it is revoked
+		lock.Session.Orphan()
+	}
+
+	ctx, cancel := context.WithTimeout(cli.Context, cli.RequestTimeout)
+	defer cancel()
+
+	_, err := cli.Client.Revoke(ctx, leaseId)
+	if err != nil && err != rpctypes.ErrLeaseNotFound {
+		return errors.New(fmt.Sprintf("Error releasing lock '%s': %s", name, err.Error()))
+	}
+
+	return nil
+}
+
+//Releases the lock if it is held by this provider process
+func ReleaseSessionLock(leaseId clientv3.LeaseID) error {
+	heldLocks.Lock()
+	lock, ok := heldLocks.locks[leaseId]
+	delete(heldLocks.locks, leaseId)
+	heldLocks.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lock.RequestTimeout)
+	defer cancel()
+
+	err := lock.Mutex.Unlock(ctx)
+	if err != nil {
+		lock.Session.Close()
+		return errors.New(fmt.Sprintf("Error releasing lock '%s': %s", lock.Name, err.Error()))
+	}
+
+	return lock.Session.Close()
+}
+
+/*
+Releases all the locks held by this provider process, except the persistent ones whose leases are simply no longer kept alive.
+Meant to be called when the provider shuts down so that the locks do not linger until their lease expires.
+*/
+func ReleaseAllSessionLocks() {
+	heldLocks.Lock()
+	leaseIds := []clientv3.LeaseID{}
+	for leaseId, lock := range heldLocks.locks {
+		if lock.Persistent {
+			lock.Session.Orphan()
+			delete(heldLocks.locks, leaseId)
+			continue
+		}
+		leaseIds = append(leaseIds, leaseId)
+	}
+	heldLocks.Unlock()
+
+	for _, leaseId := range leaseIds {
+		ReleaseSessionLock(leaseId)
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 func init() {
@@ -112,22 +113,66 @@ func Provider() *schema.Provider {
 				Optional:    true,
 				Default:     false,
 			},
+			"lock": &schema.Schema{
+				Description: "Distributed lock (etcd mutex) to acquire when the provider starts so that only one terraform project using the same lock runs at a time. The lock is held for as long as the provider runs, including during plans, and is released when the provider shuts down or, if the provider exits abruptly, when the lease of its session expires.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Description:  "Name of the lock. It is the key prefix of the mutex, following the same conventions as the etcdctl lock command.",
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.StringIsNotEmpty,
+						},
+						"ttl": {
+							Description:  "Time to live in seconds of the lease of the lock's session. It is the time it takes for the lock to be released if the provider exits abruptly. Defaults to 60.",
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      60,
+							ValidateFunc: validation.IntAtLeast(1),
+						},
+						"timeout": {
+							Description:  "Maximum duration to wait for the lock to be acquired before failing. Defaults to 5m.",
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "5m",
+							ValidateFunc: validateDuration,
+						},
+					},
+				},
+			},
 		},
 
 		ResourcesMap: map[string]*schema.Resource{
 			"etcd_auth":                      resourceAuth(),
 			"etcd_role":                      resourceRole(),
 			"etcd_user":                      resourceUser(),
+			"etcd_user_role_binding":         resourceUserRoleBinding(),
+			"etcd_role_permission":           resourceRolePermission(),
+			"etcd_acl_policy":                resourceAclPolicy(),
 			"etcd_key":                       resourceKey(),
 			"etcd_key_prefix":                resourceKeyPrefix(),
 			"etcd_range_scoped_state":        resourceRangeScopedState(),
 			"etcd_synchronized_key_prefixes": resourceSynchronizedKeyPrefixes(),
 			"etcd_synchronized_directory":    resourceSynchronizedDirectory(),
+			"etcd_key_range_import":          resourceKeyRangeImport(),
+			"etcd_key_range_export":          resourceKeyRangeExport(),
+			"etcd_lock":                      resourceLock(),
+			"etcd_wait":                      resourceWait(),
+			"etcd_transaction":               resourceTransaction(),
 		},
 		DataSourcesMap: map[string]*schema.Resource{
 			"etcd_prefix_range_end": dataSourcePrefixRangeEnd(),
 			"etcd_key_range":        dataSourceKeyRange(),
 			"etcd_key":              dataSourceKey(),
+			"etcd_user":             dataSourceUser(),
+			"etcd_users":            dataSourceUsers(),
+			"etcd_role":             dataSourceRole(),
+			"etcd_roles":            dataSourceRoles(),
+			"etcd_access_check":     dataSourceAccessCheck(),
+			"etcd_election_leader":  dataSourceElectionLeader(),
 		},
 		ConfigureFunc: providerConfigure,
 		//Should implement close once this issue is resolved: https://github.com/hashicorp/terraform-plugin-sdk/issues/63
@@ -169,5 +214,23 @@ func providerConfigure(d *schema.ResourceData) (interface{}, error) {
 		return nil, errors.New(fmt.Sprintf("Failed to connect to etcd servers: %s", cliErr.Error()))
 	}
 
+	if cert != "" {
+		commonName, certErr := GetCertCommonName(cert)
+		if certErr != nil {
+			return nil, certErr
+		}
+		SetClientCertUsername(cli, commonName)
+	}
+
+	locks, _ := d.Get("lock").([]interface{})
+	if len(locks) > 0 {
+		lock := locks[0].(map[string]interface{})
+		lockTimeoutDuration, _ := time.ParseDuration(lock["timeout"].(string))
+		_, lockErr := AcquireSessionLock(cli, lock["name"].(string), lock["ttl"].(int), lockTimeoutDuration, false)
+		if lockErr != nil {
+			return nil, lockErr
+		}
+	}
+
 	return cli, nil
 }
@@ -0,0 +1,528 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func resourceAclPolicy() *schema.Resource {
+	return &schema.Resource{
+		Description: "Manages roles, their permissions and the roles of users in bulk from a policy document. Users of the policy that do not exist must have **no_password** set to true in the policy to be created, in which case they are created without a password and are expected to authenticate with client certificates. The root role and user cannot be part of the policy. Roles and users of the policy should not be managed by other resources. Existing roles and users can be imported with a policy document listing their names as the id (ex: `{\"roles\": [{\"name\": \"reader\"}], \"users\": [{\"username\": \"alice\"}]}`), in which case their current permissions and roles become the policy.",
+		Create:      resourceAclPolicyCreate,
+		Read:        resourceAclPolicyRead,
+		Update:      resourceAclPolicyUpdate,
+		Delete:      resourceAclPolicyDelete,
+		Importer: &schema.ResourceImporter{
+			State: resourceAclPolicyImport,
+		},
+		Schema: map[string]*schema.Schema{
+			"policy": {
+				Description: "Yaml or json document describing the policy. It has a **roles** list where each role has a **name** and a **permissions** list. Each permission has a **permission** (read, write or readwrite), a **key** and either a **range_end** or **prefix** set to true. It also has a **users** list where each user has a **username**, a **roles** list and an optional **no_password** flag allowing the user to be created without a password if it does not exist. Differences in formatting that do not change the policy are ignored. If the roles or users drift from the policy, the actual policy is rendered as json in the plan.",
+				Type:        schema.TypeString,
+				Required:    true,
+				ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+					_, err := ParseAclPolicy(val.(string))
+					if err != nil {
+						return []string{}, []error{err}
+					}
+
+					return []string{}, []error{}
+				},
+				DiffSuppressFunc: func(k, oldValue, newValue string, d *schema.ResourceData) bool {
+					oldPolicy, oldErr := ParseAclPolicy(oldValue)
+					newPolicy, newErr := ParseAclPolicy(newValue)
+					if oldErr != nil || newErr != nil {
+						return false
+					}
+
+					return oldPolicy.Equals(newPolicy)
+				},
+			},
+			"mode": {
+				Description:  "Reconciliation mode of the policy. Can be: authoritative or additive. In authoritative mode, roles and users of the policy have exactly the permissions and roles of the policy and those removed from the policy are deleted if the resource created them. In additive mode, permissions and roles of the policy are granted without revoking the others and those removed from the policy are revoked, but roles and users are never deleted. Roles and users the resource did not create are never deleted: when they are removed from the policy, only the permissions and roles the policy granted them are revoked. Changing the mode reconciles all the roles and users of the policy with the new mode. Defaults to additive.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "additive",
+				ValidateFunc: validation.StringInSlice([]string{"authoritative", "additive"}, false),
+			},
+			"created_roles": {
+				Description: "Roles of the policy that did not exist and were created by the resource. In authoritative mode, they are deleted when they are removed from the policy or the resource is destroyed.",
+				Type:        schema.TypeSet,
+				Computed:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"created_users": {
+				Description: "Users of the policy that did not exist and were created by the resource. In authoritative mode, they are deleted when they are removed from the policy or the resource is destroyed.",
+				Type:        schema.TypeSet,
+				Computed:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+func aclPolicySchemaToModel(policyVal interface{}) (AclPolicy, error) {
+	return ParseAclPolicy(policyVal.(string))
+}
+
+/*
+Roles and users that the resource created and is allowed to delete.
+*/
+type AclPolicyCreations struct {
+	Roles []string
+	Users []string
+}
+
+func aclPolicyCreationsSchemaToModel(d *schema.ResourceData) AclPolicyCreations {
+	creations := AclPolicyCreations{Roles: []string{}, Users: []string{}}
+
+	for _, val := range d.Get("created_roles").(*schema.Set).List() {
+		creations.Roles = append(creations.Roles, val.(string))
+	}
+
+	for _, val := range d.Get("created_users").(*schema.Set).List() {
+		creations.Users = append(creations.Users, val.(string))
+	}
+
+	return creations
+}
+
+func aclPolicyCreationsModelToSchema(creations AclPolicyCreations, d *schema.ResourceData) {
+	d.Set("created_roles", creations.Roles)
+	d.Set("created_users", creations.Users)
+}
+
+func removeString(values []string, value string) []string {
+	return slices.DeleteFunc(values, func(val string) bool {
+		return val == value
+	})
+}
+
+func revokeRolePermissionIfGranted(cli *client.EtcdClient, roleName string, permission client.EtcdRolePermission, resPermissions []client.EtcdRolePermission) error {
+	for _, resPermission := range resPermissions {
+		if resPermission.Key == permission.Key && resPermission.RangeEnd == permission.RangeEnd {
+			err := cli.RevokeRolePermission(roleName, permission.Key, permission.RangeEnd)
+			if err != nil {
+				return errors.New(fmt.Sprintf("Error removing role permission (key='%s', range_end='%s', permission='%s') for role '%s': %s", permission.Key, permission.RangeEnd, permission.Permission, roleName, err.Error()))
+			}
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func applyAclPolicyRole(cli *client.EtcdClient, mode string, oldRole client.EtcdRole, newRole client.EtcdRole, creations *AclPolicyCreations) error {
+	resPermissions, roleExists, err := cli.GetRolePermissions(newRole.Name)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error retrieving existing role '%s': %s", newRole.Name, err.Error()))
+	}
+
+	if !roleExists {
+		err := cli.InsertRole(newRole)
+		if err != nil {
+			return err
+		}
+
+		if !slices.Contains(creations.Roles, newRole.Name) {
+			creations.Roles = append(creations.Roles, newRole.Name)
+		}
+		return nil
+	}
+
+	if mode == "authoritative" {
+		err := cli.UpdateRole(newRole)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error applying role '%s': %s", newRole.Name, err.Error()))
+		}
+		return nil
+	}
+
+	//Only the permissions previously granted by the policy are revoked
+	_, revokes := diffRolePermissions(oldRole.Permissions, newRole.Permissions)
+	for _, permission := range revokes {
+		err := revokeRolePermissionIfGranted(cli, newRole.Name, permission, resPermissions)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, permission := range newRole.Permissions {
+		if slices.Contains(resPermissions, permission) {
+			continue
+		}
+
+		err := cli.GrantRolePermission(newRole.Name, permission)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error adding role permission (key='%s', range_end='%s', permission='%s') for role '%s': %s", permission.Key, permission.RangeEnd, permission.Permission, newRole.Name, err.Error()))
+		}
+	}
+
+	return nil
+}
+
+//Roles the resource did not create are never deleted, only revoked the permissions of the policy
+func removeAclPolicyRole(cli *client.EtcdClient, mode string, oldRole client.EtcdRole, creations *AclPolicyCreations) error {
+	resPermissions, roleExists, err := cli.GetRolePermissions(oldRole.Name)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error retrieving existing role '%s': %s", oldRole.Name, err.Error()))
+	}
+
+	if !roleExists {
+		creations.Roles = removeString(creations.Roles, oldRole.Name)
+		return nil
+	}
+
+	if mode == "authoritative" && slices.Contains(creations.Roles, oldRole.Name) {
+		err := cli.DeleteRole(oldRole.Name)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error deleting role '%s': %s", oldRole.Name, err.Error()))
+		}
+		creations.Roles = removeString(creations.Roles, oldRole.Name)
+		return nil
+	}
+
+	for _, permission := range oldRole.Permissions {
+		err := revokeRolePermissionIfGranted(cli, oldRole.Name, permission, resPermissions)
+		if err != nil {
+			return err
+		}
+	}
+	creations.Roles = removeString(creations.Roles, oldRole.Name)
+
+	return nil
+}
+
+func applyAclPolicyUser(cli *client.EtcdClient, mode string, oldUser client.EtcdUser, newUser client.EtcdUser, noPassword bool, creations *AclPolicyCreations) error {
+	resRoles, userExists, err := cli.GetUserRoles(newUser.Username)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error retrieving existing user '%s': %s", newUser.Username, err.Error()))
+	}
+
+	if !userExists {
+		if !noPassword {
+			return errors.New(fmt.Sprintf("User '%s' of the acl policy does not exist and cannot be created unless no_password is set to true for it in the policy", newUser.Username))
+		}
+
+		err := insertNoPasswordUser(cli, newUser)
+		if err != nil {
+			return err
+		}
+
+		if !slices.Contains(creations.Users, newUser.Username) {
+			creations.Users = append(creations.Users, newUser.Username)
+		}
+		return nil
+	}
+
+	//Only the roles previously granted by the policy are revoked, unless the policy is authoritative
+	revocableRoles := oldUser.Roles
+	if mode == "authoritative" {
+		revocableRoles = resRoles
+	}
+
+	for _, role := range revocableRoles {
+		if !slices.Contains(newUser.Roles, role) && slices.Contains(resRoles, role) {
+			err := cli.RevokeUserRole(newUser.Username, role)
+			if err != nil {
+				return errors.New(fmt.Sprintf("Error removing role '%s' from user '%s': %s", role, newUser.Username, err.Error()))
+			}
+		}
+	}
+
+	for _, role := range newUser.Roles {
+		if !slices.Contains(resRoles, role) {
+			err := cli.GrantUserRole(newUser.Username, role)
+			if err != nil {
+				return errors.New(fmt.Sprintf("Error adding role '%s' to user '%s': %s", role, newUser.Username, err.Error()))
+			}
+		}
+	}
+
+	return nil
+}
+
+//Users the resource did not create are never deleted, only revoked the roles of the policy
+func removeAclPolicyUser(cli *client.EtcdClient, mode string, oldUser client.EtcdUser, creations *AclPolicyCreations) error {
+	resRoles, userExists, err := cli.GetUserRoles(oldUser.Username)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error retrieving existing user '%s': %s", oldUser.Username, err.Error()))
+	}
+
+	if !userExists {
+		creations.Users = removeString(creations.Users, oldUser.Username)
+		return nil
+	}
+
+	if mode == "authoritative" && slices.Contains(creations.Users, oldUser.Username) {
+		err := cli.DeleteUser(oldUser.Username)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error deleting user '%s': %s", oldUser.Username, err.Error()))
+		}
+		creations.Users = removeString(creations.Users, oldUser.Username)
+		return nil
+	}
+
+	for _, role := range oldUser.Roles {
+		if slices.Contains(resRoles, role) {
+			err := cli.RevokeUserRole(oldUser.Username, role)
+			if err != nil {
+				return errors.New(fmt.Sprintf("Error removing role '%s' from user '%s': %s", role, oldUser.Username, err.Error()))
+			}
+		}
+	}
+	creations.Users = removeString(creations.Users, oldUser.Username)
+
+	return nil
+}
+
+/*
+Reconciles the etcd roles and users from the previous policy to the new one.
+Roles are applied before users so that users can be granted the roles of the policy
+and users are removed before roles.
+All the roles and users of the new policy are applied, not only those that changed, so that they are reconciled with the mode if it changed.
+The roles and users the resource creates or deletes are tracked in the creations, even if an error occurs.
+*/
+func applyAclPolicy(cli *client.EtcdClient, mode string, oldPolicy AclPolicy, newPolicy AclPolicy, creations *AclPolicyCreations) error {
+	for _, newRole := range newPolicy.Roles {
+		oldRole, _ := oldPolicy.GetRole(newRole.Name)
+		err := applyAclPolicyRole(cli, mode, oldRole, newRole, creations)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, newUser := range newPolicy.Users {
+		oldUser, _ := oldPolicy.GetUser(newUser.Username)
+		noPassword := slices.Contains(newPolicy.NoPasswordUsers, newUser.Username)
+		err := applyAclPolicyUser(cli, mode, oldUser, newUser, noPassword, creations)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, oldUser := range oldPolicy.Users {
+		if _, exists := newPolicy.GetUser(oldUser.Username); !exists {
+			err := removeAclPolicyUser(cli, mode, oldUser, creations)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, oldRole := range oldPolicy.Roles {
+		if _, exists := newPolicy.GetRole(oldRole.Name); !exists {
+			err := removeAclPolicyRole(cli, mode, oldRole, creations)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+/*
+Returns the roles and users of the policy as they are in etcd.
+In additive mode, only the permissions and roles of the policy are considered.
+*/
+func getActualAclPolicy(cli *client.EtcdClient, mode string, policy AclPolicy) (AclPolicy, error) {
+	actual := AclPolicy{Roles: []client.EtcdRole{}, Users: []client.EtcdUser{}, NoPasswordUsers: []string{}}
+
+	for _, role := range policy.Roles {
+		resPermissions, roleExists, err := cli.GetRolePermissions(role.Name)
+		if err != nil {
+			return actual, errors.New(fmt.Sprintf("Error retrieving existing role '%s' for reading: %s", role.Name, err.Error()))
+		}
+
+		if !roleExists {
+			continue
+		}
+
+		actualRole := client.EtcdRole{Name: role.Name, Permissions: []client.EtcdRolePermission{}}
+		for _, resPermission := range resPermissions {
+			if mode == "authoritative" || slices.Contains(role.Permissions, resPermission) {
+				actualRole.Permissions = append(actualRole.Permissions, resPermission)
+			}
+		}
+		actual.Roles = append(actual.Roles, actualRole)
+	}
+
+	for _, user := range policy.Users {
+		resRoles, userExists, err := cli.GetUserRoles(user.Username)
+		if err != nil {
+			return actual, errors.New(fmt.Sprintf("Error retrieving existing user '%s' for reading: %s", user.Username, err.Error()))
+		}
+
+		if !userExists {
+			continue
+		}
+
+		actualUser := client.EtcdUser{Username: user.Username, Roles: []string{}}
+		for _, resRole := range resRoles {
+			if mode == "authoritative" || slices.Contains(user.Roles, resRole) {
+				actualUser.Roles = append(actualUser.Roles, resRole)
+			}
+		}
+		actual.Users = append(actual.Users, actualUser)
+		if slices.Contains(policy.NoPasswordUsers, user.Username) {
+			actual.NoPasswordUsers = append(actual.NoPasswordUsers, user.Username)
+		}
+	}
+
+	actual.normalize()
+	return actual, nil
+}
+
+func resourceAclPolicyCreate(d *schema.ResourceData, meta interface{}) error {
+	cli := meta.(*client.EtcdClient)
+	mode := d.Get("mode").(string)
+
+	policy, err := aclPolicySchemaToModel(d.Get("policy"))
+	if err != nil {
+		return err
+	}
+
+	id, err := policy.GetId()
+	if err != nil {
+		return err
+	}
+
+	//The id is set beforehand so that the roles and users created before an error are kept in the state
+	d.SetId(id)
+	creations := AclPolicyCreations{Roles: []string{}, Users: []string{}}
+	err = applyAclPolicy(cli, mode, AclPolicy{Roles: []client.EtcdRole{}, Users: []client.EtcdUser{}, NoPasswordUsers: []string{}}, policy, &creations)
+	aclPolicyCreationsModelToSchema(creations, d)
+	if err != nil {
+		return err
+	}
+
+	return resourceAclPolicyRead(d, meta)
+}
+
+func resourceAclPolicyRead(d *schema.ResourceData, meta interface{}) error {
+	cli := meta.(*client.EtcdClient)
+	mode := d.Get("mode").(string)
+
+	policy, err := aclPolicySchemaToModel(d.Get("policy"))
+	if err != nil {
+		return err
+	}
+
+	actual, err := getActualAclPolicy(cli, mode, policy)
+	if err != nil {
+		return err
+	}
+
+	if !actual.Equals(policy) {
+		rendered, err := RenderAclPolicy(actual)
+		if err != nil {
+			return err
+		}
+		d.Set("policy", rendered)
+	}
+
+	return nil
+}
+
+func resourceAclPolicyUpdate(d *schema.ResourceData, meta interface{}) error {
+	cli := meta.(*client.EtcdClient)
+	mode := d.Get("mode").(string)
+
+	oldPolicyVal, newPolicyVal := d.GetChange("policy")
+	oldPolicy, err := aclPolicySchemaToModel(oldPolicyVal)
+	if err != nil {
+		return err
+	}
+
+	newPolicy, err := aclPolicySchemaToModel(newPolicyVal)
+	if err != nil {
+		return err
+	}
+
+	creations := aclPolicyCreationsSchemaToModel(d)
+	err = applyAclPolicy(cli, mode, oldPolicy, newPolicy, &creations)
+	aclPolicyCreationsModelToSchema(creations, d)
+	if err != nil {
+		return err
+	}
+
+	id, err := newPolicy.GetId()
+	if err != nil {
+		return err
+	}
+
+	d.SetId(id)
+	return resourceAclPolicyRead(d, meta)
+}
+
+func resourceAclPolicyDelete(d *schema.ResourceData, meta interface{}) error {
+	cli := meta.(*client.EtcdClient)
+	mode := d.Get("mode").(string)
+
+	policy, err := aclPolicySchemaToModel(d.Get("policy"))
+	if err != nil {
+		return err
+	}
+
+	creations := aclPolicyCreationsSchemaToModel(d)
+	err = applyAclPolicy(cli, mode, policy, AclPolicy{Roles: []client.EtcdRole{}, Users: []client.EtcdUser{}, NoPasswordUsers: []string{}}, &creations)
+	aclPolicyCreationsModelToSchema(creations, d)
+	return err
+}
+
+/*
+The import id is a policy document listing the roles and users to import, whose permissions and roles are ignored.
+The current permissions and roles of those roles and users become the policy and none of them are considered created by the resource.
+*/
+func resourceAclPolicyImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	cli := meta.(*client.EtcdClient)
+
+	names, err := ParseAclPolicy(d.Id())
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error parsing import id: %s", err.Error()))
+	}
+
+	policy, err := getActualAclPolicy(cli, "authoritative", names)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, role := range names.Roles {
+		if _, exists := policy.GetRole(role.Name); !exists {
+			return nil, errors.New(fmt.Sprintf("Error importing acl policy: role '%s' does not exist", role.Name))
+		}
+	}
+
+	for _, user := range names.Users {
+		if _, exists := policy.GetUser(user.Username); !exists {
+			return nil, errors.New(fmt.Sprintf("Error importing acl policy: user '%s' does not exist", user.Username))
+		}
+	}
+
+	rendered, err := RenderAclPolicy(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := policy.GetId()
+	if err != nil {
+		return nil, err
+	}
+
+	d.SetId(id)
+	d.Set("policy", rendered)
+	d.Set("mode", "additive")
+	aclPolicyCreationsModelToSchema(AclPolicyCreations{Roles: []string{}, Users: []string{}}, d)
+
+	return []*schema.ResourceData{d}, nil
+}
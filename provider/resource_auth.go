@@ -1,17 +1,27 @@
 package provider
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
 	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
 )
 
 func resourceAuth() *schema.Resource {
 	return &schema.Resource{
-		Description: "Controls the authentication status (enabled or disabled) of the etcd cluster.",
-		Create:      resourceAuthUpsert,
-		Read:        resourceAuthRead,
-		Delete:      resourceAuthDelete,
-		Update:      resourceAuthUpsert,
+		Description:   "Controls the authentication status (enabled or disabled) of the etcd cluster. Before enabling authentication, the resource checks that the root user exists with the root role and that the user the provider authenticates with exists with the root role so that the provider does not lock itself out. The check is made at plan time and again before authentication is enabled. If the provider authenticates with a client certificate instead of a username, its user is the common name of the certificate.",
+		Create:        resourceAuthUpsert,
+		Read:          resourceAuthRead,
+		Delete:        resourceAuthDelete,
+		Update:        resourceAuthUpsert,
+		CustomizeDiff: resourceAuthCustomizeDiff,
 		Importer: &schema.ResourceImporter{
 			State: schema.ImportStatePassthrough,
 		},
@@ -21,10 +31,89 @@ func resourceAuth() *schema.Resource {
 				Type:        schema.TypeBool,
 				Required:    true,
 			},
+			"skip_enablement_checks": {
+				Description: "If set to true, authentication is enabled without checking the root user and the provider's user beforehand. Useful if those users are created in the same apply as the checks are made at plan time when they do not exist yet. Defaults to false.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"on_destroy": {
+				Description:  "Behavior when the resource is destroyed. Can be: keep (authentication is left in its current state) or disable (authentication is disabled). Defaults to keep.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "keep",
+				ValidateFunc: validation.StringInSlice([]string{"keep", "disable"}, false),
+			},
+			"token_type": {
+				Description: "Type of the authentication tokens issued by the etcd servers. Can be: simple, jwt or unknown. Etcd does not report it directly so it is inferred from a token issued to the provider's user. To avoid issuing a token on every refresh, it is only inferred once authentication is enabled or the resource is imported and is not updated if the etcd servers are reconfigured afterward. It is unknown if authentication is disabled or if the provider does not authenticate with a username and password.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
 		},
 	}
 }
 
+/*
+Etcd refuses to enable authentication without a root user having the root role
+and the provider's user will be denied further operations if it lacks the root role once authentication is enabled.
+*/
+func checkAuthEnablement(cli *client.EtcdClient) error {
+	enabled, err := cli.GetAuthStatus()
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error retrieving authentication status: %s", err.Error()))
+	}
+
+	if enabled {
+		return nil
+	}
+
+	rootRoles, rootExists, err := cli.GetUserRoles("root")
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error retrieving the root user: %s", err.Error()))
+	}
+
+	if !rootExists {
+		return errors.New("Cannot enable authentication: the root user does not exist")
+	}
+
+	if !slices.Contains(rootRoles, "root") {
+		return errors.New("Cannot enable authentication: the root user does not have the root role")
+	}
+
+	username := GetClientUsername(cli)
+	if username == "" || username == "root" {
+		return nil
+	}
+
+	roles, userExists, err := cli.GetUserRoles(username)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error retrieving the provider's user '%s': %s", username, err.Error()))
+	}
+
+	if !userExists {
+		return errors.New(fmt.Sprintf("Cannot enable authentication: the provider's user '%s' does not exist and the provider would lose access to etcd", username))
+	}
+
+	if !slices.Contains(roles, "root") {
+		return errors.New(fmt.Sprintf("Cannot enable authentication: the provider's user '%s' does not have the root role and the provider would lose the ability to manage authentication", username))
+	}
+
+	return nil
+}
+
+func resourceAuthCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if d.HasChange("enabled") {
+		d.SetNewComputed("token_type")
+	}
+
+	if !d.NewValueKnown("enabled") || !d.Get("enabled").(bool) || d.Get("skip_enablement_checks").(bool) {
+		return nil
+	}
+
+	cli := meta.(*client.EtcdClient)
+	return checkAuthEnablement(cli)
+}
+
 func resourceAuthRead(d *schema.ResourceData, meta interface{}) error {
 	cli := meta.(*client.EtcdClient)
 
@@ -33,17 +122,66 @@ func resourceAuthRead(d *schema.ResourceData, meta interface{}) error {
 		return err
 	}
 
+	//Inferring the token type issues a token so it is only done once after authentication is enabled
+	tokenType := d.Get("token_type").(string)
+	if !enabled {
+		tokenType = "unknown"
+	} else if tokenType == "" || tokenType == "unknown" {
+		tokenType, err = getAuthTokenType(cli)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error retrieving authentication token type: %s", err.Error()))
+		}
+	}
+
 	d.Set("enabled", enabled)
+	d.Set("token_type", tokenType)
 
 	return nil
 }
 
+/*
+Simple tokens are a random string followed by a dot and an index while jwt tokens are made of three dot separated segments.
+*/
+func getAuthTokenType(cli *client.EtcdClient) (string, error) {
+	if cli.Client.Username == "" || cli.Client.Password == "" {
+		return "unknown", nil
+	}
+
+	ctx, cancel := context.WithTimeout(cli.Context, cli.RequestTimeout)
+	defer cancel()
+
+	res, err := cli.Client.Authenticate(ctx, cli.Client.Username, cli.Client.Password)
+	if err != nil {
+		if err == rpctypes.ErrAuthNotEnabled {
+			return "unknown", nil
+		}
+
+		return "unknown", err
+	}
+
+	switch len(strings.Split(res.Token, ".")) {
+	case 2:
+		return "simple", nil
+	case 3:
+		return "jwt", nil
+	default:
+		return "unknown", nil
+	}
+}
+
 func resourceAuthUpsert(d *schema.ResourceData, meta interface{}) error {
 	enabledVar, _ := d.GetOk("enabled")
 	enabled := enabledVar.(bool)
 
 	cli := meta.(*client.EtcdClient)
 
+	if enabled && !d.Get("skip_enablement_checks").(bool) {
+		err := checkAuthEnablement(cli)
+		if err != nil {
+			return err
+		}
+	}
+
 	err := cli.SetAuthStatus(enabled)
 	if err != nil {
 		return err
@@ -54,5 +192,16 @@ func resourceAuthUpsert(d *schema.ResourceData, meta interface{}) error {
 }
 
 func resourceAuthDelete(d *schema.ResourceData, meta interface{}) error {
+	if d.Get("on_destroy").(string) != "disable" {
+		return nil
+	}
+
+	cli := meta.(*client.EtcdClient)
+
+	err := cli.SetAuthStatus(false)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error disabling authentication: %s", err.Error()))
+	}
+
 	return nil
 }
@@ -0,0 +1,243 @@
+package provider
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func resourceKeyRangeExport() *schema.Resource {
+	return &schema.Resource{
+		Description: "Writes the keys contained in a key range to a local file, sorted by key. The file can be in the json format (an array of entries), the jsonl format (one entry per line) or the yaml format. Each entry contains the **key**, its base64 encoded **value**, its **version**, **create_revision**, **mod_revision** and **lease**. Files in the json or jsonl format can be loaded back with the etcd_key_range_import resource.",
+		Create:      resourceKeyRangeExportCreate,
+		Read:        resourceKeyRangeExportRead,
+		Delete:      resourceKeyRangeExportDelete,
+		Update:      resourceKeyRangeExportUpdate,
+		Schema: map[string]*schema.Schema{
+			"key": {
+				Description:  "Key specifying the beginning of the key range.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"range_end": {
+				Description:  "Key specifying the end of the key range (exclusive). To you set it to the value of the key scopes the range to a single key. If you would like the range to be anything prefixed by the key, you can use the etcd_prefix_range_end data helper.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"file": {
+				Description:  "Path of the file to write the keys to.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"format": &schema.Schema{
+				Description: "Format of the file. Can be one of: json, jsonl, yaml. Defaults to json.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "json",
+				ForceNew:    false,
+				ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+					format := val.(string)
+					if format != "json" && format != "jsonl" && format != "yaml" {
+						return []string{}, []error{errors.New("The format field must be one of the following: json, jsonl, yaml")}
+					}
+					return []string{}, []error{}
+				},
+			},
+			"file_permission": &schema.Schema{
+				Description: "Permission of the generated file.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "0600",
+				ForceNew:    false,
+				ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+					permission := val.(string)
+					iPermission, err := strconv.ParseInt(permission, 8, 32)
+					if err != nil || iPermission < 0 || iPermission > 511 {
+						return []string{}, []error{errors.New("The file_permission field must constitute a valid unix value for file permissions")}
+					}
+					return []string{}, []error{}
+				},
+			},
+			"recurrence": &schema.Schema{
+				Description: "Defines when the resource should be recreated to trigger a new export. Can be set to once, onchange or always. Note that onchange looks for change during the plan phase only so consider setting it to always if another terraform resource in your script changes the key range.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "onchange",
+				ForceNew:    false,
+				ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+					recurrence := val.(string)
+					if recurrence != "once" && recurrence != "onchange" && recurrence != "always" {
+						return []string{}, []error{errors.New("The recurrence field must be one of the following: once, onchange, always")}
+					}
+					return []string{}, []error{}
+				},
+			},
+			"clear_on_deletion": &schema.Schema{
+				Description: "Whether to delete the file when the resource is deleted.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				ForceNew:    false,
+			},
+			"revision": {
+				Description: "Revision of the etcd keystore when the key range was exported.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"keys_count": {
+				Description: "Number of keys that were exported.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+type KeyRangeExport struct {
+	Key             string
+	RangeEnd        string
+	File            string
+	Format          string
+	FilePermission  int32
+	Recurrence      string
+	ClearOnDeletion bool
+}
+
+func (state KeyRangeExport) GetId() KeyRangeId {
+	return KeyRangeId{state.Key, state.RangeEnd}
+}
+
+func keyRangeExportSchemaToModel(d *schema.ResourceData) KeyRangeExport {
+	model := KeyRangeExport{}
+
+	model.Key = d.Get("key").(string)
+	model.RangeEnd = d.Get("range_end").(string)
+	model.File = d.Get("file").(string)
+	model.Format = d.Get("format").(string)
+	model.Recurrence = d.Get("recurrence").(string)
+	model.ClearOnDeletion = d.Get("clear_on_deletion").(bool)
+
+	fPermission, _ := strconv.ParseInt(d.Get("file_permission").(string), 8, 32)
+	model.FilePermission = int32(fPermission)
+
+	return model
+}
+
+func exportKeyRange(cli *client.EtcdClient, keyRangeExport KeyRangeExport) (client.KeyRangeInfo, []byte, error) {
+	keyInfos, err := cli.GetKeyRange(keyRangeExport.Key, keyRangeExport.RangeEnd)
+	if err != nil {
+		return keyInfos, nil, errors.New(fmt.Sprintf("Error retrieving key range (key='%s', range_end='%s'): %s", keyRangeExport.Key, keyRangeExport.RangeEnd, err.Error()))
+	}
+
+	content, renderErr := RenderKeyRangeFile(keyInfos.Keys, keyRangeExport.Format)
+	if renderErr != nil {
+		return keyInfos, nil, errors.New(fmt.Sprintf("Error rendering key range (key='%s', range_end='%s') in %s format: %s", keyRangeExport.Key, keyRangeExport.RangeEnd, keyRangeExport.Format, renderErr.Error()))
+	}
+
+	return keyInfos, content, nil
+}
+
+func writeKeyRangeExport(d *schema.ResourceData, cli *client.EtcdClient, keyRangeExport KeyRangeExport) error {
+	keyInfos, content, err := exportKeyRange(cli, keyRangeExport)
+	if err != nil {
+		return err
+	}
+
+	writeErr := ioutil.WriteFile(keyRangeExport.File, content, os.FileMode(keyRangeExport.FilePermission))
+	if writeErr != nil {
+		return errors.New(fmt.Sprintf("Error writing key range (key='%s', range_end='%s') to file '%s': %s", keyRangeExport.Key, keyRangeExport.RangeEnd, keyRangeExport.File, writeErr.Error()))
+	}
+
+	chmodErr := os.Chmod(keyRangeExport.File, os.FileMode(keyRangeExport.FilePermission))
+	if chmodErr != nil {
+		return errors.New(fmt.Sprintf("Error setting permission of file '%s': %s", keyRangeExport.File, chmodErr.Error()))
+	}
+
+	d.Set("revision", keyInfos.Revision)
+	d.Set("keys_count", len(keyInfos.Keys))
+
+	return nil
+}
+
+func resourceKeyRangeExportCreate(d *schema.ResourceData, meta interface{}) error {
+	keyRangeExport := keyRangeExportSchemaToModel(d)
+	cli := meta.(*client.EtcdClient)
+
+	err := writeKeyRangeExport(d, cli, keyRangeExport)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(keyRangeExport.GetId().Serialize())
+	return nil
+}
+
+func resourceKeyRangeExportRead(d *schema.ResourceData, meta interface{}) error {
+	keyRangeExport := keyRangeExportSchemaToModel(d)
+	cli := meta.(*client.EtcdClient)
+
+	if keyRangeExport.Recurrence == "once" {
+		return nil
+	}
+
+	if keyRangeExport.Recurrence == "always" {
+		d.SetId("")
+		return nil
+	}
+
+	fileContent, fileErr := ioutil.ReadFile(keyRangeExport.File)
+	if fileErr != nil {
+		if os.IsNotExist(fileErr) {
+			d.SetId("")
+			return nil
+		}
+
+		return errors.New(fmt.Sprintf("Error reading file '%s': %s", keyRangeExport.File, fileErr.Error()))
+	}
+
+	_, content, err := exportKeyRange(cli, keyRangeExport)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(fileContent, content) {
+		d.SetId("")
+	}
+
+	return nil
+}
+
+func resourceKeyRangeExportUpdate(d *schema.ResourceData, meta interface{}) error {
+	keyRangeExport := keyRangeExportSchemaToModel(d)
+	cli := meta.(*client.EtcdClient)
+
+	return writeKeyRangeExport(d, cli, keyRangeExport)
+}
+
+func resourceKeyRangeExportDelete(d *schema.ResourceData, meta interface{}) error {
+	keyRangeExport := keyRangeExportSchemaToModel(d)
+
+	if !keyRangeExport.ClearOnDeletion {
+		return nil
+	}
+
+	err := os.Remove(keyRangeExport.File)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.New(fmt.Sprintf("Error deleting file '%s': %s", keyRangeExport.File, err.Error()))
+	}
+
+	return nil
+}
@@ -0,0 +1,360 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func resourceKeyRangeImport() *schema.Resource {
+	return &schema.Resource{
+		Description: "Loads the keys defined in a file into a key range. The file can either be in the json format (an array of entries) or the jsonl format (one entry per line). Each entry is an object with a **key** property, a **value** property containing the base64 encoded value of the key and an optional **lease_ttl** property indicating the number of seconds the key should live for. Files generated by the etcd_key_range_export resource in the json or jsonl format are also accepted, the additional properties they contain being ignored. The changes are applied in transactions of at most 128 operations (the default max number of operations per transaction of etcd) and 1MiB, so an import that fails partway leaves the key range partially imported until it is applied again.",
+		Create:      resourceKeyRangeImportCreate,
+		Read:        resourceKeyRangeImportRead,
+		Delete:      resourceKeyRangeImportDelete,
+		Update:      resourceKeyRangeImportUpdate,
+		Schema: map[string]*schema.Schema{
+			"key": {
+				Description:  "Key specifying the beginning of the key range.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"range_end": {
+				Description:  "Key specifying the end of the key range (exclusive). To you set it to the value of the key scopes the range to a single key. If you would like the range to be anything prefixed by the key, you can use the etcd_prefix_range_end data helper.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"file": {
+				Description:  "Path of the file containing the keys to import. All the keys in the file must be contained in the key range.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     false,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"format": &schema.Schema{
+				Description: "Format of the file. Can be one of: json, jsonl. Defaults to json.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "json",
+				ForceNew:    false,
+				ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+					format := val.(string)
+					if format != "json" && format != "jsonl" {
+						return []string{}, []error{errors.New("The format field must be one of the following: json, jsonl")}
+					}
+					return []string{}, []error{}
+				},
+			},
+			"prune": &schema.Schema{
+				Description: "Whether to delete the keys in the range that are not present in the file.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				ForceNew:    false,
+			},
+			"recurrence": &schema.Schema{
+				Description: "Defines when the resource should be recreated to trigger a new import. Can be set to once, onchange or always. Note that onchange looks for change during the plan phase only so consider setting it to always if another terraform resource in your script changes the file.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "onchange",
+				ForceNew:    false,
+				ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+					recurrence := val.(string)
+					if recurrence != "once" && recurrence != "onchange" && recurrence != "always" {
+						return []string{}, []error{errors.New("The recurrence field must be one of the following: once, onchange, always")}
+					}
+					return []string{}, []error{}
+				},
+			},
+		},
+	}
+}
+
+type KeyRangeImport struct {
+	Key        string
+	RangeEnd   string
+	File       string
+	Format     string
+	Prune      bool
+	Recurrence string
+}
+
+func (state KeyRangeImport) GetId() KeyRangeId {
+	return KeyRangeId{state.Key, state.RangeEnd}
+}
+
+func keyRangeImportSchemaToModel(d *schema.ResourceData) KeyRangeImport {
+	model := KeyRangeImport{}
+
+	model.Key = d.Get("key").(string)
+	model.RangeEnd = d.Get("range_end").(string)
+	model.File = d.Get("file").(string)
+	model.Format = d.Get("format").(string)
+	model.Prune = d.Get("prune").(bool)
+	model.Recurrence = d.Get("recurrence").(string)
+
+	return model
+}
+
+/*
+Changes to apply on the key range to make it like the file.
+The lease ttls of the inserted and updated keys are indexed by key, keys without a ttl are not leased.
+The leases the key range keys currently have are also indexed by key so that they can be revoked once replaced.
+*/
+type KeyRangeImportDiff struct {
+	Diff        client.KeyDiff
+	LeaseTtls   map[string]int64
+	RangeLeases map[string]int64
+}
+
+//Returns the ttl each lease was granted with. Leases that expired in the meantime are reported with a ttl of 0.
+func getLeaseTtls(cli *client.EtcdClient, leases []int64) (map[int64]int64, error) {
+	ttls := map[int64]int64{0: 0}
+	for _, lease := range leases {
+		if _, ok := ttls[lease]; ok {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(cli.Context, cli.RequestTimeout)
+		ttlResp, ttlErr := cli.Client.TimeToLive(ctx, clientv3.LeaseID(lease))
+		cancel()
+		if ttlErr != nil {
+			return ttls, errors.New(fmt.Sprintf("Error retrieving ttl of lease %d: %s", lease, ttlErr.Error()))
+		}
+
+		ttls[lease] = 0
+		if ttlResp.TTL != -1 {
+			ttls[lease] = ttlResp.GrantedTTL
+		}
+	}
+
+	return ttls, nil
+}
+
+/*
+Returns the changes to apply on the key range to make it like the file.
+Keys whose value is unchanged are still updated if they are not leased with the ttl of the file.
+Deletions are only included if pruning is enabled.
+*/
+func diffKeyRangeWithFile(cli *client.EtcdClient, keyRangeImport KeyRangeImport) (KeyRangeImportDiff, error) {
+	fileKeys, leaseTtls, fileErr := ReadKeyRangeFile(keyRangeImport.File, keyRangeImport.Format, keyRangeImport.Key, keyRangeImport.RangeEnd)
+	if fileErr != nil {
+		return KeyRangeImportDiff{}, fileErr
+	}
+
+	rangeKeys, rangeErr := cli.GetKeyRange(keyRangeImport.Key, keyRangeImport.RangeEnd)
+	if rangeErr != nil {
+		return KeyRangeImportDiff{}, rangeErr
+	}
+
+	rangeLeases := make(map[string]int64)
+	leases := []int64{}
+	for key, info := range rangeKeys.Keys {
+		if info.Lease != 0 {
+			rangeLeases[key] = info.Lease
+			leases = append(leases, info.Lease)
+		}
+	}
+
+	leaseGrantedTtls, ttlErr := getLeaseTtls(cli, leases)
+	if ttlErr != nil {
+		return KeyRangeImportDiff{}, ttlErr
+	}
+
+	diff := client.GetKeyDiff(fileKeys, rangeKeys.Keys.ToValueMap(""))
+	for key, value := range fileKeys {
+		_, exists := rangeKeys.Keys[key]
+		_, updated := diff.Updates[key]
+		if exists && !updated && leaseTtls[key] != leaseGrantedTtls[rangeLeases[key]] {
+			diff.Updates[key] = value
+		}
+	}
+
+	if !keyRangeImport.Prune {
+		diff.Deletions = []string{}
+	}
+
+	return KeyRangeImportDiff{Diff: diff, LeaseTtls: leaseTtls, RangeLeases: rangeLeases}, nil
+}
+
+func grantLeases(cli *client.EtcdClient, ttls []int64) (map[int64]clientv3.LeaseID, error) {
+	leases := make(map[int64]clientv3.LeaseID)
+	for _, ttl := range ttls {
+		if _, ok := leases[ttl]; ok {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(cli.Context, cli.RequestTimeout)
+		leaseResp, leaseErr := cli.Client.Grant(ctx, ttl)
+		cancel()
+		if leaseErr != nil {
+			return leases, errors.New(fmt.Sprintf("Error creating lease with ttl of %d seconds: %s", ttl, leaseErr.Error()))
+		}
+		leases[ttl] = leaseResp.ID
+	}
+
+	return leases, nil
+}
+
+//Revokes the leases that no key is attached to anymore. Leases that already expired are ignored.
+func revokeUnusedLeases(cli *client.EtcdClient, leases []clientv3.LeaseID) error {
+	for _, lease := range leases {
+		ctx, cancel := context.WithTimeout(cli.Context, cli.RequestTimeout)
+		ttlResp, ttlErr := cli.Client.TimeToLive(ctx, lease, clientv3.WithAttachedKeys())
+		cancel()
+		if ttlErr != nil {
+			return errors.New(fmt.Sprintf("Error retrieving keys attached to lease %d: %s", lease, ttlErr.Error()))
+		}
+
+		if ttlResp.TTL == -1 || len(ttlResp.Keys) > 0 {
+			continue
+		}
+
+		ctx, cancel = context.WithTimeout(cli.Context, cli.RequestTimeout)
+		_, revokeErr := cli.Client.Revoke(ctx, lease)
+		cancel()
+		if revokeErr != nil && revokeErr != rpctypes.ErrLeaseNotFound {
+			return errors.New(fmt.Sprintf("Error revoking lease %d: %s", lease, revokeErr.Error()))
+		}
+	}
+
+	return nil
+}
+
+/*
+Applies the changes in as many transactions as needed to stay under the limits of etcd.
+If a transaction fails, the changes of the previous ones remain and the next import applies the rest.
+*/
+func commitKeyRangeImport(cli *client.EtcdClient, diff client.KeyDiff, leaseTtls map[string]int64, leases map[int64]clientv3.LeaseID) error {
+	keyLeases := make(map[string]clientv3.LeaseID)
+	for key, ttl := range leaseTtls {
+		if lease, ok := leases[ttl]; ok {
+			keyLeases[key] = lease
+		}
+	}
+
+	for _, ops := range batchDiffOps("", diff, keyLeases, maxTxnBytes) {
+		_, txErr := commitTxnWithRetries(cli, []clientv3.Cmp{}, ops, cli.Retries)
+		if txErr != nil {
+			return txErr
+		}
+	}
+
+	return nil
+}
+
+/*
+The leases of the keys are granted first so that the keys are attached to them as they are put.
+If the changes fail to be applied, the granted leases that no key was attached to are revoked.
+The leases the replaced or deleted keys had are revoked afterward if no other key is attached to them.
+*/
+func applyKeyRangeImport(cli *client.EtcdClient, keyRangeImport KeyRangeImport) error {
+	importDiff, diffErr := diffKeyRangeWithFile(cli, keyRangeImport)
+	if diffErr != nil {
+		return errors.New(fmt.Sprintf("Error getting differential of key range ['%s', '%s') and file '%s': %s", keyRangeImport.Key, keyRangeImport.RangeEnd, keyRangeImport.File, diffErr.Error()))
+	}
+
+	diff := importDiff.Diff
+	if diff.IsEmpty() {
+		return nil
+	}
+
+	ttls := []int64{}
+	replacedLeases := []clientv3.LeaseID{}
+	for _, changes := range []map[string]string{diff.Inserts, diff.Updates} {
+		for key := range changes {
+			if ttl, leased := importDiff.LeaseTtls[key]; leased {
+				ttls = append(ttls, ttl)
+			}
+			if lease, ok := importDiff.RangeLeases[key]; ok {
+				replacedLeases = append(replacedLeases, clientv3.LeaseID(lease))
+			}
+		}
+	}
+	for _, key := range diff.Deletions {
+		if lease, ok := importDiff.RangeLeases[key]; ok {
+			replacedLeases = append(replacedLeases, clientv3.LeaseID(lease))
+		}
+	}
+
+	leases, applyErr := grantLeases(cli, ttls)
+	if applyErr == nil {
+		applyErr = commitKeyRangeImport(cli, diff, importDiff.LeaseTtls, leases)
+	}
+
+	if applyErr != nil {
+		grantedLeases := []clientv3.LeaseID{}
+		for _, lease := range leases {
+			grantedLeases = append(grantedLeases, lease)
+		}
+		revokeUnusedLeases(cli, grantedLeases)
+		return errors.New(fmt.Sprintf("Error applying key changes to key range ['%s', '%s'): %s", keyRangeImport.Key, keyRangeImport.RangeEnd, applyErr.Error()))
+	}
+
+	revokeErr := revokeUnusedLeases(cli, replacedLeases)
+	if revokeErr != nil {
+		return errors.New(fmt.Sprintf("Error revoking replaced leases of key range ['%s', '%s'): %s", keyRangeImport.Key, keyRangeImport.RangeEnd, revokeErr.Error()))
+	}
+
+	return nil
+}
+
+func resourceKeyRangeImportCreate(d *schema.ResourceData, meta interface{}) error {
+	keyRangeImport := keyRangeImportSchemaToModel(d)
+	cli := meta.(*client.EtcdClient)
+
+	err := applyKeyRangeImport(cli, keyRangeImport)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(keyRangeImport.GetId().Serialize())
+	return nil
+}
+
+func resourceKeyRangeImportRead(d *schema.ResourceData, meta interface{}) error {
+	keyRangeImport := keyRangeImportSchemaToModel(d)
+	cli := meta.(*client.EtcdClient)
+
+	if keyRangeImport.Recurrence == "once" {
+		return nil
+	}
+
+	if keyRangeImport.Recurrence == "always" {
+		d.SetId("")
+		return nil
+	}
+
+	importDiff, err := diffKeyRangeWithFile(cli, keyRangeImport)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error getting differential of key range ['%s', '%s') and file '%s': %s", keyRangeImport.Key, keyRangeImport.RangeEnd, keyRangeImport.File, err.Error()))
+	}
+
+	if !importDiff.Diff.IsEmpty() {
+		d.SetId("")
+	}
+
+	return nil
+}
+
+func resourceKeyRangeImportUpdate(d *schema.ResourceData, meta interface{}) error {
+	keyRangeImport := keyRangeImportSchemaToModel(d)
+	cli := meta.(*client.EtcdClient)
+
+	return applyKeyRangeImport(cli, keyRangeImport)
+}
+
+func resourceKeyRangeImportDelete(d *schema.ResourceData, meta interface{}) error {
+	return nil
+}
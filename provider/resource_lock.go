@@ -0,0 +1,152 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func validateDuration(val interface{}, key string) (warns []string, errs []error) {
+	v := val.(string)
+	_, err := time.ParseDuration(v)
+	if err != nil {
+		return []string{}, []error{errors.New(fmt.Sprintf("%s must be a valid golang duration string value", key))}
+	}
+
+	return []string{}, []error{}
+}
+
+func resourceLock() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Acquires a distributed lock (etcd mutex) when created so that resources depending on it are only applied by one terraform project at a time. The lock is held until the resource is destroyed, which releases it. Its lease is kept alive for as long as the provider runs and, once the provider exits, it expires after its ttl unless a later run of the provider refreshes the resource and keeps it alive again. The resource is removed from the state if the key of the lock no longer exists in etcd (ex: its lease expired), in which case it is re-created on the next apply. The name cannot be the same as the name of the lock of the provider as the provider already holds it.",
+		Create:        resourceLockCreate,
+		Read:          resourceLockRead,
+		Delete:        resourceLockDelete,
+		CustomizeDiff: resourceLockCustomizeDiff,
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Description:  "Name of the lock. It is the key prefix of the mutex, following the same conventions as the etcdctl lock command.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"ttl": {
+				Description:  "Time to live in seconds of the lease of the lock. It is the time the lock outlives the provider, after which it is released if no later run of the provider kept it alive. Defaults to 3600.",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      3600,
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			"timeout": {
+				Description:  "Maximum duration to wait for the lock to be acquired before failing. Defaults to 5m.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      "5m",
+				ValidateFunc: validateDuration,
+			},
+			"key": {
+				Description: "Key created in etcd to hold the lock.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"lease_id": {
+				Description: "Id of the lease of the lock.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func checkLockName(name string) error {
+	if IsProviderLock(name) {
+		return errors.New(fmt.Sprintf("Lock '%s' is the lock of the provider, which the provider already holds", name))
+	}
+
+	return nil
+}
+
+func resourceLockCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if !d.NewValueKnown("name") {
+		return nil
+	}
+
+	return checkLockName(d.Get("name").(string))
+}
+
+func resourceLockCreate(d *schema.ResourceData, meta interface{}) error {
+	cli := meta.(*client.EtcdClient)
+	name := d.Get("name").(string)
+	ttl := d.Get("ttl").(int)
+	timeout, _ := time.ParseDuration(d.Get("timeout").(string))
+
+	err := checkLockName(name)
+	if err != nil {
+		return err
+	}
+
+	lock, err := AcquireSessionLock(cli, name, ttl, timeout, true)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(name)
+	d.Set("key", lock.Mutex.Key())
+	d.Set("lease_id", strconv.FormatInt(int64(lock.Session.Lease()), 10))
+
+	return resourceLockRead(d, meta)
+}
+
+func resourceLockRead(d *schema.ResourceData, meta interface{}) error {
+	cli := meta.(*client.EtcdClient)
+	key := d.Get("key").(string)
+	leaseId, err := strconv.ParseInt(d.Get("lease_id").(string), 10, 64)
+	if key == "" || err != nil {
+		d.SetId("")
+		return nil
+	}
+
+	//The key of the lock is deleted when the lock is released or the lease of its session expires
+	keyInfo, err := cli.GetKey(key, client.GetKeyOptions{})
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error retrieving key '%s' of lock '%s': %s", key, d.Id(), err.Error()))
+	}
+
+	if !keyInfo.Found() || keyInfo.Lease != leaseId {
+		d.SetId("")
+		return nil
+	}
+
+	//The lock may have been acquired by a previous run of the provider
+	held, err := ResumePersistentLock(cli, d.Id(), clientv3.LeaseID(leaseId))
+	if err != nil {
+		return err
+	}
+
+	if !held {
+		d.SetId("")
+	}
+
+	return nil
+}
+
+func resourceLockDelete(d *schema.ResourceData, meta interface{}) error {
+	cli := meta.(*client.EtcdClient)
+	leaseId, err := strconv.ParseInt(d.Get("lease_id").(string), 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	return ReleasePersistentLock(cli, d.Id(), clientv3.LeaseID(leaseId))
+}
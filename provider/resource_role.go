@@ -1,21 +1,26 @@
 package provider
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sort"
 
 	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 func resourceRole() *schema.Resource {
 	return &schema.Resource{
-		Description: "User role for etcd to define access control.",
-		Create:      resourceRoleCreate,
-		Read:        resourceRoleRead,
-		Delete:      resourceRoleDelete,
-		Update:      resourceRoleUpdate,
+		Description:   "User role for etcd to define access control.",
+		Create:        resourceRoleCreate,
+		Read:          resourceRoleRead,
+		Delete:        resourceRoleDelete,
+		Update:        resourceRoleUpdate,
+		CustomizeDiff: resourceRoleCustomizeDiff,
 		Importer: &schema.ResourceImporter{
 			State: schema.ImportStatePassthrough,
 		},
@@ -56,15 +61,80 @@ func resourceRole() *schema.Resource {
 							ValidateFunc: validation.StringIsNotEmpty,
 						},
 						"range_end": {
-							Description:  "Key specifying the end of the key range (exclusive). To you set it to the value of the key to grant permission on a single key. If you would like the range to be anything prefixed by the key, you can use the etcd_prefix_range_end data helper.",
+							Description:  "Key specifying the end of the key range (exclusive). To you set it to the value of the key to grant permission on a single key. It cannot be lower than the key, except for the special value \\x00 which includes all the keys greater or equal to the key. Required unless prefix is set to true.",
 							Type:         schema.TypeString,
-							Required:     true,
+							Optional:     true,
 							ForceNew:     false,
 							ValidateFunc: validation.StringIsNotEmpty,
 						},
+						"prefix": {
+							Description: "If set to true, the permission is granted on all the keys prefixed by the key and the range end is computed accordingly. Cannot be used with range_end.",
+							Type:        schema.TypeBool,
+							Optional:    true,
+							ForceNew:    false,
+							Default:     false,
+						},
 					},
 				},
 			},
+			"effective_ranges": {
+				Description: "Key ranges on which the role is effectively granted read and write access, with overlapping and adjacent ranges merged together. A readwrite permission grants both types of access.",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"access": {
+							Description: "Type of access granted on the key range. Can be: read or write",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"key": {
+							Description: "Key specifying the beginning of the key range.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"range_end": {
+							Description: "Key specifying the end of the key range (exclusive). It is equal to the key if the range contains a single key.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+					},
+				},
+			},
+			"permission_grants": {
+				Description: "Permissions granted to the role by the planned update or, once applied, by the last update. Permissions whose type changed on an existing key range are included.",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        rolePermissionChangeSchema(),
+			},
+			"permission_revokes": {
+				Description: "Permissions revoked from the role by the planned update or, once applied, by the last update.",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        rolePermissionChangeSchema(),
+			},
+		},
+	}
+}
+
+func rolePermissionChangeSchema() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"permission": {
+				Description: "Permission on the key range. Can be: read, write or readwrite",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"key": {
+				Description: "Key specifying the beginning of the key range.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"range_end": {
+				Description: "Key specifying the end of the key range (exclusive).",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
 		},
 	}
 }
@@ -75,17 +145,158 @@ func roleSchemaToModel(d *schema.ResourceData) client.EtcdRole {
 	name, _ := d.GetOk("name")
 	model.Name = name.(string)
 
+	permissions, permissionsExist := d.GetOk("permissions")
+	if permissionsExist {
+		for _, val := range (permissions.(*schema.Set)).List() {
+			model.Permissions = append(model.Permissions, rolePermissionSchemaElemToModel(val.(map[string]interface{})))
+		}
+	}
+
+	return model
+}
+
+func rolePermissionSchemaElemToModel(permission map[string]interface{}) client.EtcdRolePermission {
+	rangeEnd := permission["range_end"].(string)
+	if permission["prefix"].(bool) {
+		rangeEnd = clientv3.GetPrefixRangeEnd(permission["key"].(string))
+	}
+
+	return client.EtcdRolePermission{Permission: permission["permission"].(string), Key: permission["key"].(string), RangeEnd: rangeEnd}
+}
+
+//Permissions of the role that were declared with the prefix shorthand, with their range end computed
+func rolePrefixPermissions(d *schema.ResourceData) []client.EtcdRolePermission {
+	prefixPermissions := []client.EtcdRolePermission{}
+
 	permissions, permissionsExist := d.GetOk("permissions")
 	if permissionsExist {
 		for _, val := range (permissions.(*schema.Set)).List() {
 			permission := val.(map[string]interface{})
-			model.Permissions = append(model.Permissions, client.EtcdRolePermission{Permission: permission["permission"].(string), Key: permission["key"].(string), RangeEnd: permission["range_end"].(string)})
+			if permission["prefix"].(bool) {
+				prefixPermissions = append(prefixPermissions, rolePermissionSchemaElemToModel(permission))
+			}
 		}
 	}
 
+	return prefixPermissions
+}
+
+func rolePermissionsSetToModel(permissions *schema.Set) []client.EtcdRolePermission {
+	model := []client.EtcdRolePermission{}
+	for _, val := range permissions.List() {
+		model = append(model, rolePermissionSchemaElemToModel(val.(map[string]interface{})))
+	}
 	return model
 }
 
+/*
+Returns the permissions to grant and revoke to go from the current to the desired permissions of a role.
+Etcd keeps a single permission per key range so a permission whose type changed only needs to be granted again.
+*/
+func diffRolePermissions(current []client.EtcdRolePermission, desired []client.EtcdRolePermission) ([]client.EtcdRolePermission, []client.EtcdRolePermission) {
+	grants := []client.EtcdRolePermission{}
+	revokes := []client.EtcdRolePermission{}
+
+	for _, desiredPermission := range desired {
+		granted := false
+		for _, currentPermission := range current {
+			if currentPermission == desiredPermission {
+				granted = true
+			}
+		}
+
+		if !granted {
+			grants = append(grants, desiredPermission)
+		}
+	}
+
+	for _, currentPermission := range current {
+		kept := false
+		for _, desiredPermission := range desired {
+			if currentPermission.Key == desiredPermission.Key && currentPermission.RangeEnd == desiredPermission.RangeEnd {
+				kept = true
+			}
+		}
+
+		if !kept {
+			revokes = append(revokes, currentPermission)
+		}
+	}
+
+	for _, permissions := range [][]client.EtcdRolePermission{grants, revokes} {
+		sort.SliceStable(permissions, func(i, j int) bool {
+			if permissions[i].Key != permissions[j].Key {
+				return permissions[i].Key < permissions[j].Key
+			}
+			return permissions[i].RangeEnd < permissions[j].RangeEnd
+		})
+	}
+
+	return grants, revokes
+}
+
+func rolePermissionChangesToSchema(permissions []client.EtcdRolePermission) []map[string]interface{} {
+	changes := make([]map[string]interface{}, 0)
+	for _, permission := range permissions {
+		changes = append(changes, map[string]interface{}{
+			"permission": permission.Permission,
+			"key":        permission.Key,
+			"range_end":  permission.RangeEnd,
+		})
+	}
+	return changes
+}
+
+func resourceRoleCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if d.HasChange("permissions") {
+		d.SetNewComputed("effective_ranges")
+	}
+
+	if !d.NewValueKnown("permissions") {
+		d.SetNewComputed("permission_grants")
+		d.SetNewComputed("permission_revokes")
+		return nil
+	}
+
+	permissions, permissionsExist := d.GetOk("permissions")
+	if permissionsExist {
+		for _, val := range (permissions.(*schema.Set)).List() {
+			permission := val.(map[string]interface{})
+			key := permission["key"].(string)
+			rangeEnd := permission["range_end"].(string)
+
+			if permission["prefix"].(bool) {
+				if rangeEnd != "" {
+					return errors.New(fmt.Sprintf("Permission on key '%s' cannot have both a range_end and prefix set to true", key))
+				}
+				continue
+			}
+
+			if rangeEnd == "" {
+				return errors.New(fmt.Sprintf("Permission on key '%s' must have either a range_end or prefix set to true", key))
+			}
+
+			err := KeyRange{Key: key, RangeEnd: rangeEnd}.Validate()
+			if err != nil {
+				return errors.New(fmt.Sprintf("Permission on key '%s' is invalid: %s", key, err.Error()))
+			}
+		}
+	}
+
+	//The changes of the last update are kept in the state until the permissions change again
+	if d.Id() == "" || d.HasChange("permissions") {
+		oldPermissions, newPermissions := d.GetChange("permissions")
+		grants, revokes := diffRolePermissions(
+			rolePermissionsSetToModel(oldPermissions.(*schema.Set)),
+			rolePermissionsSetToModel(newPermissions.(*schema.Set)),
+		)
+		d.SetNew("permission_grants", rolePermissionChangesToSchema(grants))
+		d.SetNew("permission_revokes", rolePermissionChangesToSchema(revokes))
+	}
+
+	return nil
+}
+
 func resourceRoleCreate(d *schema.ResourceData, meta interface{}) error {
 	role := roleSchemaToModel(d)
 	cli := meta.(*client.EtcdClient)
@@ -96,6 +307,9 @@ func resourceRoleCreate(d *schema.ResourceData, meta interface{}) error {
 	}
 
 	d.SetId(role.Name)
+	grants, _ := diffRolePermissions([]client.EtcdRolePermission{}, role.Permissions)
+	d.Set("permission_grants", rolePermissionChangesToSchema(grants))
+	d.Set("permission_revokes", rolePermissionChangesToSchema([]client.EtcdRolePermission{}))
 	return resourceRoleRead(d, meta)
 }
 
@@ -114,23 +328,88 @@ func resourceRoleRead(d *schema.ResourceData, meta interface{}) error {
 	}
 
 	d.Set("name", roleName)
+	d.Set("permissions", rolePermissionsToSchema(resPermissions, rolePrefixPermissions(d)))
+	d.Set("effective_ranges", roleEffectiveRangesToSchema(resPermissions))
+
+	return nil
+}
+
+/*
+Permissions matching one of the prefix permissions are expressed with the prefix shorthand
+so that they match the way they were declared.
+*/
+func rolePermissionsToSchema(resPermissions []client.EtcdRolePermission, prefixPermissions []client.EtcdRolePermission) []map[string]interface{} {
 	permissions := make([]map[string]interface{}, 0)
 	for _, resPermission := range resPermissions {
+		isPrefix := false
+		for _, prefixPermission := range prefixPermissions {
+			if prefixPermission == resPermission {
+				isPrefix = true
+			}
+		}
+
+		if isPrefix {
+			permissions = append(permissions, map[string]interface{}{
+				"permission": resPermission.Permission,
+				"key":        resPermission.Key,
+				"range_end":  "",
+				"prefix":     true,
+			})
+			continue
+		}
+
 		permissions = append(permissions, map[string]interface{}{
 			"permission": resPermission.Permission,
 			"key":        resPermission.Key,
 			"range_end":  resPermission.RangeEnd,
+			"prefix":     false,
 		})
 	}
-	d.Set("permissions", permissions)
+	return permissions
+}
 
-	return nil
+func roleEffectiveRangesToSchema(resPermissions []client.EtcdRolePermission) []map[string]interface{} {
+	effectiveRanges := make([]map[string]interface{}, 0)
+	accessRanges := GetEffectiveRanges(resPermissions)
+	for _, access := range []string{"read", "write"} {
+		for _, keyRange := range accessRanges[access] {
+			effectiveRanges = append(effectiveRanges, map[string]interface{}{
+				"access":    access,
+				"key":       keyRange.Key,
+				"range_end": keyRange.RangeEnd,
+			})
+		}
+	}
+	return effectiveRanges
 }
 
 func resourceRoleUpdate(d *schema.ResourceData, meta interface{}) error {
 	role := roleSchemaToModel(d)
 	cli := meta.(*client.EtcdClient)
-	cli.UpsertRole(role)
+
+	oldPermissions, newPermissions := d.GetChange("permissions")
+	grants, revokes := diffRolePermissions(
+		rolePermissionsSetToModel(oldPermissions.(*schema.Set)),
+		rolePermissionsSetToModel(newPermissions.(*schema.Set)),
+	)
+
+	for _, permission := range revokes {
+		err := cli.RevokeRolePermission(role.Name, permission.Key, permission.RangeEnd)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error removing role permission (key='%s', range_end='%s', permission='%s') for role '%s': %s", permission.Key, permission.RangeEnd, permission.Permission, role.Name, err.Error()))
+		}
+	}
+
+	for _, permission := range grants {
+		err := cli.GrantRolePermission(role.Name, permission)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error adding role permission (key='%s', range_end='%s', permission='%s') for role '%s': %s", permission.Key, permission.RangeEnd, permission.Permission, role.Name, err.Error()))
+		}
+	}
+
+	d.Set("permission_grants", rolePermissionChangesToSchema(grants))
+	d.Set("permission_revokes", rolePermissionChangesToSchema(revokes))
+
 	return resourceRoleRead(d, meta)
 }
 
@@ -0,0 +1,177 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func resourceRolePermission() *schema.Resource {
+	return &schema.Resource{
+		Description: "Grants a single permission on a key range to an existing role without affecting the other permissions of the role. Useful when several terraform projects need to grant permissions to the same role. It should not be used on a role whose permissions are managed by an etcd_role resource as the two would conflict. A role created with an etcd_role resource can still be used if that resource ignores changes to its permissions with the ignore_changes lifecycle argument. Note that etcd keeps a single permission per key range for a given role.",
+		Create:      resourceRolePermissionCreate,
+		Read:        resourceRolePermissionRead,
+		Delete:      resourceRolePermissionDelete,
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
+		Schema: map[string]*schema.Schema{
+			"role": {
+				Description:  "Name of the role to grant the permission to.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"permission": {
+				Description: "Permission to grant to the role on the given key range. Can be: read, write or readwrite",
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				ValidateFunc: func(val interface{}, key string) (warns []string, errs []error) {
+					v := val.(string)
+					if v != "read" && v != "write" && v != "readwrite" {
+						return []string{}, []error{errors.New("Permission value for role can only be one of the followings: read, write, readwrite")}
+					}
+
+					return []string{}, []error{}
+				},
+			},
+			"key": {
+				Description:  "Key specifying the beginning of the key range.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"range_end": {
+				Description:  "Key specifying the end of the key range (exclusive). To you set it to the value of the key to grant permission on a single key. If you would like the range to be anything prefixed by the key, you can use the etcd_prefix_range_end data helper.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+		},
+	}
+}
+
+type RolePermissionId struct {
+	Role     string
+	Key      string
+	RangeEnd string
+}
+
+//Needed to absolutely ensure it is deterministic
+func (id RolePermissionId) MarshalJSON() ([]byte, error) {
+	mRole, _ := json.Marshal(id.Role)
+	mKey, _ := json.Marshal(id.Key)
+	mRangeEnd, _ := json.Marshal(id.RangeEnd)
+	return []byte(fmt.Sprintf("{\"Role\":%s,\"Key\":%s,\"RangeEnd\":%s}", string(mRole), string(mKey), string(mRangeEnd))), nil
+}
+
+func (id RolePermissionId) Serialize() string {
+	out, _ := json.Marshal(id)
+	return string(out)
+}
+
+func DeserializeRolePermissionId(id string) (RolePermissionId, error) {
+	var rolePermissionId RolePermissionId
+	err := json.Unmarshal([]byte(id), &rolePermissionId)
+	return rolePermissionId, err
+}
+
+type RolePermission struct {
+	Role       string
+	Permission client.EtcdRolePermission
+}
+
+func (state RolePermission) GetId() RolePermissionId {
+	return RolePermissionId{state.Role, state.Permission.Key, state.Permission.RangeEnd}
+}
+
+func rolePermissionSchemaToModel(d *schema.ResourceData) RolePermission {
+	return RolePermission{
+		Role: d.Get("role").(string),
+		Permission: client.EtcdRolePermission{
+			Permission: d.Get("permission").(string),
+			Key:        d.Get("key").(string),
+			RangeEnd:   d.Get("range_end").(string),
+		},
+	}
+}
+
+func resourceRolePermissionCreate(d *schema.ResourceData, meta interface{}) error {
+	rolePermission := rolePermissionSchemaToModel(d)
+	cli := meta.(*client.EtcdClient)
+
+	err := cli.GrantRolePermission(rolePermission.Role, rolePermission.Permission)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error adding role permission (key='%s', range_end='%s', permission='%s') for role '%s': %s", rolePermission.Permission.Key, rolePermission.Permission.RangeEnd, rolePermission.Permission.Permission, rolePermission.Role, err.Error()))
+	}
+
+	d.SetId(rolePermission.GetId().Serialize())
+	return resourceRolePermissionRead(d, meta)
+}
+
+func resourceRolePermissionRead(d *schema.ResourceData, meta interface{}) error {
+	id, idErr := DeserializeRolePermissionId(d.Id())
+	if idErr != nil {
+		return errors.New(fmt.Sprintf("Error parsing role permission id '%s': %s", d.Id(), idErr.Error()))
+	}
+	cli := meta.(*client.EtcdClient)
+
+	resPermissions, roleExists, err := cli.GetRolePermissions(id.Role)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error retrieving existing role '%s' for reading: %s", id.Role, err.Error()))
+	}
+
+	if !roleExists {
+		d.SetId("")
+		return nil
+	}
+
+	for _, resPermission := range resPermissions {
+		if resPermission.Key == id.Key && resPermission.RangeEnd == id.RangeEnd {
+			d.Set("role", id.Role)
+			d.Set("permission", resPermission.Permission)
+			d.Set("key", resPermission.Key)
+			d.Set("range_end", resPermission.RangeEnd)
+			return nil
+		}
+	}
+
+	d.SetId("")
+	return nil
+}
+
+func resourceRolePermissionDelete(d *schema.ResourceData, meta interface{}) error {
+	rolePermission := rolePermissionSchemaToModel(d)
+	cli := meta.(*client.EtcdClient)
+
+	resPermissions, _, err := cli.GetRolePermissions(rolePermission.Role)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error retrieving existing role '%s' for deletion: %s", rolePermission.Role, err.Error()))
+	}
+
+	granted := false
+	for _, resPermission := range resPermissions {
+		if resPermission.Key == rolePermission.Permission.Key && resPermission.RangeEnd == rolePermission.Permission.RangeEnd {
+			granted = true
+		}
+	}
+
+	if !granted {
+		return nil
+	}
+
+	err = cli.RevokeRolePermission(rolePermission.Role, rolePermission.Permission.Key, rolePermission.Permission.RangeEnd)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error removing role permission (key='%s', range_end='%s', permission='%s') for role '%s': %s", rolePermission.Permission.Key, rolePermission.Permission.RangeEnd, rolePermission.Permission.Permission, rolePermission.Role, err.Error()))
+	}
+
+	return nil
+}
@@ -1,35 +1,24 @@
 package provider
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
-func DiffPrefixWithInput(cli *client.EtcdClient, prefix string, inputKeys map[string]client.KeyInfo, inputKeysPrefix string, inputIsSource bool) (client.KeyDiff, error) {
-	prefixKeys, err := cli.GetPrefix(prefix)
-	if err != nil {
-		return client.KeyDiff{}, err
-	}
-
-	inputKeysInfoMap := client.KeyInfoMap(inputKeys)
-
-	if inputIsSource {
-		return client.GetKeyDiff(inputKeysInfoMap.ToValueMap(inputKeysPrefix), prefixKeys.Keys.ToValueMap(prefix)), nil
-	}
-
-	return client.GetKeyDiff(prefixKeys.Keys.ToValueMap(prefix), inputKeysInfoMap.ToValueMap(inputKeysPrefix)), nil
-}
-
 func resourceSynchronizedDirectory() *schema.Resource {
 	return &schema.Resource{
-		Description: "Synchronizes the content of an key prefix and directory. Note that etcd is has a default max object size of 1.5MiB and is most suitable for keys that are bounded to a small size like configurations. Use another solution for larger files. Also, currently, only file systems following the unix convention are supported.",
+		Description: "Synchronizes the content of an key prefix and directory. Note that etcd is has a default max object size of 1.5MiB and is most suitable for keys that are bounded to a small size like configurations. Files that are larger than the max_file_size argument are rejected when the directory is the source. Use another solution for larger files. When the directory is the source, its changes are applied to the key prefix in transactions of at most 128 operations and 1MiB (or max_file_size if it is larger), so a synchronization with more changes is not atomic: if it fails partway, the key prefix is left partially synchronized and the next synchronization retrieves its whole content to complete it. Keys whose name would place their file outside of the directory (ex: with .. or through a symbolic link to a directory outside of it) are rejected. So are keys that are not clean paths (ex: a/../b or a//b), as several keys would otherwise map to the same file. Also, currently, only file systems following the unix convention are supported.",
 		Create:      resourceSynchronizedDirectoryCreate,
 		Read:        resourceSynchronizedDirectoryRead,
 		Delete:      resourceSynchronizedDirectoryDelete,
@@ -37,6 +26,7 @@ func resourceSynchronizedDirectory() *schema.Resource {
 		Importer: &schema.ResourceImporter{
 			State: schema.ImportStatePassthrough,
 		},
+		CustomizeDiff: resourceSynchronizedDirectoryCustomizeDiff,
 		Schema: map[string]*schema.Schema{
 			"key_prefix": {
 				Description:  "Key prefix to synchronize with the directory.",
@@ -109,6 +99,67 @@ func resourceSynchronizedDirectory() *schema.Resource {
 					return []string{}, []error{}
 				},
 			},
+			"max_file_size": &schema.Schema{
+				Description:  "Maximum size in bytes of a file, once compressed, when the directory is the source. The files exceeding it are listed during the plan. Defaults to 1048576 (1MiB), which leaves headroom for the key and the encoding of the request under the default max request size of etcd (1.5MiB). Can be set to 0 to disable the check. Changes are written in transactions of at most 128 operations (the default max number of operations per transaction of etcd) and of at most 1MiB or max_file_size bytes, whichever is larger, each transaction failing if the key prefix was modified by another writer in the meantime.",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      1048576,
+				ForceNew:     false,
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"compression": &schema.Schema{
+				Description:  "Compression of the values stored in the key prefix. Values are compressed when the directory is the source and decompressed when the key prefix is the source. Compressed values are marked with a prefix naming their compression, like the values of preserved symbolic links, so that they are never mistaken for uncompressed content. With none, values are stored and read as is and markers are ignored, so values previously compressed with another compression are not decompressed. Can be set to none, gzip or zstd.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "none",
+				ForceNew:     true,
+				ValidateFunc: validateCompression,
+			},
+			"prune_empty_directories": &schema.Schema{
+				Description: "Whether to remove the directories left empty when files are deleted from the directory, if the key prefix is the source. The synchronized directory itself is always kept.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				ForceNew:    false,
+			},
+			"symlinks": &schema.Schema{
+				Description:  "How symbolic links in the directory are handled. Can be set to skip (they are ignored), follow (the content of their target is synchronized, including the content of linked directories) or preserve (the target of the link is stored in the key prefix as a marked value and the link is recreated when the key prefix is the source). Links are only followed to read the directory when it is the source. When the key prefix is the source, links are not followed: with follow, they are ignored when comparing the directory with the key prefix and those found where files are written are replaced rather than written through. Resources synchronizing the same key prefix should handle symbolic links the same way.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "follow",
+				ForceNew:     false,
+				ValidateFunc: validateSymlinks,
+			},
+			"metadata": &schema.Schema{
+				Description: "Metadata of the files to preserve. Can contain: mode, ownership (uid and gid) and mtime. The metadata is stored in the " + synchronizedDirectoryMetadataKey + " key under the key prefix when the directory is the source and is restored when the key prefix is the source, after the files are written with the files_permission argument. Restoring the ownership usually requires the provider to run as root. Resources synchronizing the same key prefix should preserve the same metadata.",
+				Type:        schema.TypeSet,
+				Optional:    true,
+				ForceNew:    true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringInSlice([]string{"mode", "ownership", "mtime"}, false),
+				},
+			},
+			"manifest_key": &schema.Schema{
+				Description: "Key outside of the key prefix where a manifest of the sha256 hashes of the synchronized files is also stored, in addition to the state. If the key prefix did not change since the manifest was stored, the manifest is used instead of retrieving the content of the key prefix. Useful when several resources synchronize the same key prefix. It is opt-in as there is no key outside of the key prefix that the provider can assume it is allowed to write to and that other resources synchronizing the key prefix would agree on.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "",
+				ForceNew:    true,
+			},
+			"manifest": &schema.Schema{
+				Description: "Sha256 hashes of the synchronized files, indexed by their path relative to the directory. Only the files whose hashes differ are synchronized and if the key prefix did not change since the last synchronization, the hashes are used instead of retrieving its content.",
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"manifest_revision": &schema.Schema{
+				Description: "Revision of the etcd store at which the manifest was known to match the content of the key prefix.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
 		},
 	}
 }
@@ -120,6 +171,42 @@ type SynchronizedDirectory struct {
 	Recurrence          string
 	FilesPermission     int32
 	DirectoryPermission int32
+	ManifestKey         string
+	MaxFileSize         int64
+	Compression         string
+	Metadata            FileMetadataFields
+	Symlinks            string
+	PruneEmptyDirs      bool
+}
+
+/*
+Handling of the symbolic links of the directory.
+Links are only followed to read the directory when it is the source.
+When the key prefix is the source, the links found in the directory are replaced by the files of the key prefix instead of being written through.
+*/
+func (state SynchronizedDirectory) DirectorySymlinks() string {
+	if state.Symlinks == "follow" && state.Source == "key-prefix" {
+		return "skip"
+	}
+
+	return state.Symlinks
+}
+
+func (state SynchronizedDirectory) DirectoryWriteOptions() DirectoryWriteOptions {
+	return DirectoryWriteOptions{
+		FilesPermission:       state.FilesPermission,
+		DirectoryPermission:   state.DirectoryPermission,
+		Symlinks:              state.DirectorySymlinks(),
+		PruneEmptyDirectories: state.PruneEmptyDirs,
+	}
+}
+
+func synchronizedDirectoryPath(directory string) string {
+	path, _ := filepath.Abs(directory)
+	if path[len(path)-1:] != "/" {
+		path = path + "/"
+	}
+	return path
 }
 
 func synchronizedDirectorySchemaToModel(d *schema.ResourceData) SynchronizedDirectory {
@@ -128,6 +215,22 @@ func synchronizedDirectorySchemaToModel(d *schema.ResourceData) SynchronizedDire
 	model.KeyPrefix = d.Get("key_prefix").(string)
 	model.Source = d.Get("source").(string)
 	model.Recurrence = d.Get("recurrence").(string)
+	model.ManifestKey = d.Get("manifest_key").(string)
+	model.MaxFileSize = int64(d.Get("max_file_size").(int))
+	model.Compression = d.Get("compression").(string)
+	model.Symlinks = d.Get("symlinks").(string)
+	model.PruneEmptyDirs = d.Get("prune_empty_directories").(bool)
+
+	for _, field := range d.Get("metadata").(*schema.Set).List() {
+		switch field.(string) {
+		case "mode":
+			model.Metadata.Mode = true
+		case "ownership":
+			model.Metadata.Ownership = true
+		case "mtime":
+			model.Metadata.Mtime = true
+		}
+	}
 
 	fPermission, _ := strconv.ParseInt(d.Get("files_permission").(string), 8, 32)
 	model.FilesPermission = int32(fPermission)
@@ -135,11 +238,7 @@ func synchronizedDirectorySchemaToModel(d *schema.ResourceData) SynchronizedDire
 	dPermission, _ := strconv.ParseInt(d.Get("directory_permission").(string), 8, 32)
 	model.DirectoryPermission = int32(dPermission)
 
-	directory, _ := filepath.Abs(d.Get("directory").(string))
-	if directory[len(directory)-1:] != "/" {
-		directory = directory + "/"
-	}
-	model.Directory = directory
+	model.Directory = synchronizedDirectoryPath(d.Get("directory").(string))
 
 	return model
 }
@@ -171,40 +270,369 @@ func DeserializeSynchronizedDirectoryId(id string) (SynchronizedDirectoryId, err
 	return synchronizedDirectoryId, err
 }
 
-func resourceSynchronizedDirectoryCreate(d *schema.ResourceData, meta interface{}) error {
-	synchronizedDirectory := synchronizedDirectorySchemaToModel(d)
-	cli := meta.(*client.EtcdClient)
+func formatOversizedFiles(oversized map[string]int64) string {
+	files := []string{}
+	for file, size := range oversized {
+		files = append(files, fmt.Sprintf("%s (%d bytes)", file, size))
+	}
+	sort.Strings(files)
+	return strings.Join(files, ", ")
+}
 
-	if synchronizedDirectory.Source == "key-prefix" {
-		EnsureDirectoryExists(synchronizedDirectory.Directory, synchronizedDirectory.DirectoryPermission)
+/*
+The size of the files is checked during the plan if the directory already exists.
+It is checked again when the files are synchronized as they may have changed since.
+*/
+func resourceSynchronizedDirectoryCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	manifestKey := d.Get("manifest_key").(string)
+	keyPrefix := d.Get("key_prefix").(string)
+	if manifestKey != "" && d.NewValueKnown("manifest_key") && d.NewValueKnown("key_prefix") && strings.HasPrefix(manifestKey, keyPrefix) {
+		return errors.New(fmt.Sprintf("The manifest_key %s must not be under the key_prefix %s", manifestKey, keyPrefix))
 	}
 
-	dirKeys, dirErr := GetDirectoryContent(synchronizedDirectory.Directory)
-	if dirErr != nil {
-		return errors.New(fmt.Sprintf("Error getting differential of prefix %s and directory %s: %s", synchronizedDirectory.KeyPrefix, synchronizedDirectory.Directory, dirErr.Error()))
+	maxFileSize := int64(d.Get("max_file_size").(int))
+	if d.Get("source").(string) != "directory" || maxFileSize == 0 || !d.NewValueKnown("directory") || !d.NewValueKnown("max_file_size") {
+		return nil
 	}
 
-	inputIsSource := synchronizedDirectory.Source == "directory"
-	diffs, err := DiffPrefixWithInput(cli, synchronizedDirectory.KeyPrefix, dirKeys, synchronizedDirectory.Directory, inputIsSource)
+	directory := synchronizedDirectoryPath(d.Get("directory").(string))
+	if _, err := os.Stat(directory); err != nil {
+		return nil
+	}
+
+	oversized, err := GetOversizedFiles(directory, maxFileSize, d.Get("compression").(string), d.Get("symlinks").(string))
 	if err != nil {
-		return errors.New(fmt.Sprintf("Error getting differential of prefix %s and directory %s: %s", synchronizedDirectory.KeyPrefix, synchronizedDirectory.Directory, err.Error()))
+		return errors.New(fmt.Sprintf("Error checking size of files in directory %s: %s", directory, err.Error()))
+	}
+
+	if len(oversized) > 0 {
+		return errors.New(fmt.Sprintf("The following files of directory %s exceed the max_file_size of %d bytes: %s", directory, maxFileSize, formatOversizedFiles(oversized)))
+	}
+
+	return nil
+}
+
+func getSynchronizedDirectoryManifest(d *schema.ResourceData) DirectoryManifest {
+	files, ok := d.GetOk("manifest")
+	if !ok {
+		return DirectoryManifest{}
+	}
+
+	manifest := DirectoryManifest{
+		Revision:    int64(d.Get("manifest_revision").(int)),
+		Compression: d.Get("compression").(string),
+		Files:       make(map[string]string),
+	}
+	for file, hash := range files.(map[string]interface{}) {
+		manifest.Files[file] = hash.(string)
+	}
+
+	return manifest
+}
+
+func setSynchronizedDirectoryManifest(d *schema.ResourceData, manifest DirectoryManifest) {
+	d.Set("manifest", manifest.Files)
+	d.Set("manifest_revision", manifest.Revision)
+}
+
+func diffKeys(diffs client.KeyDiff) []string {
+	keys := []string{}
+	for key := range diffs.Inserts {
+		keys = append(keys, key)
+	}
+	for key := range diffs.Updates {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+//Replaces the hashes in the differential with the content they were computed from
+func fillDiffContent(diffs client.KeyDiff, content map[string]string) {
+	for key := range diffs.Inserts {
+		diffs.Inserts[key] = content[key]
+	}
+	for key := range diffs.Updates {
+		diffs.Updates[key] = content[key]
+	}
+}
+
+/*
+Returns the hashes of the files of the directory.
+If metadata is preserved and the directory is the source, the metadata of the files is also returned
+and its hash is included as the hash of the metadata key so that it is synchronized like a file.
+*/
+func getSynchronizedDirectoryHashes(synchronizedDirectory SynchronizedDirectory) (map[string]string, string, error) {
+	dirHashes, err := GetDirectoryHashes(synchronizedDirectory.Directory, synchronizedDirectory.DirectorySymlinks())
+	if err != nil {
+		return dirHashes, "", err
+	}
+
+	if !synchronizedDirectory.Metadata.Enabled() || synchronizedDirectory.Source != "directory" {
+		return dirHashes, "", nil
+	}
+
+	if _, ok := dirHashes[synchronizedDirectoryMetadataKey]; ok {
+		return dirHashes, "", errors.New(fmt.Sprintf("File %s conflicts with the key where the metadata of the files is stored", synchronizedDirectoryMetadataKey))
+	}
+
+	metadata, err := GetDirectoryMetadata(synchronizedDirectory.Directory, synchronizedDirectory.Metadata, synchronizedDirectory.DirectorySymlinks())
+	if err != nil {
+		return dirHashes, "", err
+	}
+
+	metadataValue := SerializeDirectoryMetadata(metadata)
+	dirHashes[synchronizedDirectoryMetadataKey] = HashContent([]byte(metadataValue))
+
+	return dirHashes, metadataValue, nil
+}
+
+//Returns the hashes of the key prefix without the metadata key, if metadata is preserved
+func getSynchronizedKeyPrefixFiles(synchronizedDirectory SynchronizedDirectory, manifest DirectoryManifest) map[string]string {
+	if !synchronizedDirectory.Metadata.Enabled() {
+		return manifest.Files
+	}
+
+	files := make(map[string]string)
+	for file, hash := range manifest.Files {
+		if file != synchronizedDirectoryMetadataKey {
+			files[file] = hash
+		}
+	}
+	return files
+}
+
+//Returns the metadata of the files stored in the key prefix, taken from its content if it was already retrieved
+func getSynchronizedKeyPrefixMetadata(cli *client.EtcdClient, synchronizedDirectory SynchronizedDirectory, prefixContent *KeyPrefixContent) (map[string]FileMetadata, error) {
+	var value string
+	if prefixContent != nil {
+		value = prefixContent.Values[synchronizedDirectoryMetadataKey]
+	} else {
+		key := synchronizedDirectory.KeyPrefix + synchronizedDirectoryMetadataKey
+		info, err := cli.GetKey(key, client.GetKeyOptions{})
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Error retrieving key %s: %s", key, err.Error()))
+		}
+
+		value, _, err = DecompressValue(info.Value, synchronizedDirectory.Compression)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Error decompressing value of key %s: %s", key, err.Error()))
+		}
 	}
 
+	return DeserializeDirectoryMetadata(value)
+}
+
+func synchronizeKeyPrefixToDirectory(cli *client.EtcdClient, synchronizedDirectory SynchronizedDirectory, dirHashes map[string]string, prefixManifest DirectoryManifest, prefixContent *KeyPrefixContent) (DirectoryManifest, error) {
+	diffs := client.GetKeyDiff(getSynchronizedKeyPrefixFiles(synchronizedDirectory, prefixManifest), dirHashes)
 	if !diffs.IsEmpty() {
-		if synchronizedDirectory.Source == "directory" {
-			err := cli.ApplyDiffToPrefix(synchronizedDirectory.KeyPrefix, diffs)
-			if err != nil {
-				return errors.New(fmt.Sprintf("Error synchronizing changes to key prefix %s: %s", synchronizedDirectory.KeyPrefix, err.Error()))
-			}
+		//Only the changed keys are retrieved, unless they changed again since the manifest was made
+		var values map[string]string
+		if prefixContent != nil {
+			values = prefixContent.Values
 		} else {
-			err := ApplyDiffToDirectory(synchronizedDirectory.Directory, diffs, synchronizedDirectory.FilesPermission, synchronizedDirectory.DirectoryPermission)
+			var matches bool
+			var err error
+			values, matches, err = GetKeyPrefixValues(cli, synchronizedDirectory.KeyPrefix, diffKeys(diffs), prefixManifest)
 			if err != nil {
-				return errors.New(fmt.Sprintf("Error synchronizing changes to directory %s: %s", synchronizedDirectory.Directory, err.Error()))
+				return DirectoryManifest{}, errors.New(fmt.Sprintf("Error retrieving changed keys of prefix %s: %s", synchronizedDirectory.KeyPrefix, err.Error()))
+			}
+
+			if !matches {
+				prefixManifest, prefixContent, err = GetKeyPrefixContentManifest(cli, synchronizedDirectory.KeyPrefix, synchronizedDirectory.Compression)
+				if err != nil {
+					return DirectoryManifest{}, errors.New(fmt.Sprintf("Error getting differential of prefix %s and directory %s: %s", synchronizedDirectory.KeyPrefix, synchronizedDirectory.Directory, err.Error()))
+				}
+				diffs = client.GetKeyDiff(getSynchronizedKeyPrefixFiles(synchronizedDirectory, prefixManifest), dirHashes)
+				values = prefixContent.Values
+			}
+		}
+		fillDiffContent(diffs, values)
+
+		err := ApplyDiffToDirectory(synchronizedDirectory.Directory, diffs, synchronizedDirectory.DirectoryWriteOptions())
+		if err != nil {
+			return DirectoryManifest{}, errors.New(fmt.Sprintf("Error synchronizing changes to directory %s: %s", synchronizedDirectory.Directory, err.Error()))
+		}
+	}
+
+	if synchronizedDirectory.Metadata.Enabled() {
+		metadata, err := getSynchronizedKeyPrefixMetadata(cli, synchronizedDirectory, prefixContent)
+		if err != nil {
+			return DirectoryManifest{}, err
+		}
+
+		err = ApplyDirectoryMetadata(synchronizedDirectory.Directory, metadata, synchronizedDirectory.Metadata, synchronizedDirectory.DirectorySymlinks())
+		if err != nil {
+			return DirectoryManifest{}, errors.New(fmt.Sprintf("Error restoring metadata of files in directory %s: %s", synchronizedDirectory.Directory, err.Error()))
+		}
+	}
+
+	return prefixManifest, nil
+}
+
+/*
+Applies the changes of the directory to the key prefix if the prefix still matches its manifest.
+Returns false if the prefix was modified since, in which case the changes are not applied.
+If the changes are only partially applied, a partial manifest is returned along with false or the error.
+*/
+func applyDirectoryToKeyPrefix(cli *client.EtcdClient, synchronizedDirectory SynchronizedDirectory, dirHashes map[string]string, metadataValue string, prefixManifest DirectoryManifest, prefixContent *KeyPrefixContent) (DirectoryManifest, bool, error) {
+	diffs := client.GetKeyDiff(dirHashes, prefixManifest.Files)
+
+	//Values that are not stored with the expected compression are stored again even if their content did not change
+	if prefixContent != nil {
+		for _, key := range prefixContent.Uncompressed {
+			if _, ok := dirHashes[key]; ok {
+				diffs.Updates[key] = dirHashes[key]
 			}
 		}
 	}
 
+	if diffs.IsEmpty() {
+		return prefixManifest, true, nil
+	}
+
+	files := []string{}
+	metadataChanged := false
+	for _, key := range diffKeys(diffs) {
+		if synchronizedDirectory.Metadata.Enabled() && key == synchronizedDirectoryMetadataKey {
+			metadataChanged = true
+			continue
+		}
+		files = append(files, key)
+	}
+
+	dirContent, err := ReadDirectoryFiles(synchronizedDirectory.Directory, files, synchronizedDirectory.DirectorySymlinks())
+	if err != nil {
+		return DirectoryManifest{}, false, errors.New(fmt.Sprintf("Error reading changed files of directory %s: %s", synchronizedDirectory.Directory, err.Error()))
+	}
+
+	if metadataChanged {
+		dirContent[synchronizedDirectoryMetadataKey] = metadataValue
+	}
+
+	values := make(map[string]string)
+	oversized := make(map[string]int64)
+	for file, content := range dirContent {
+		value, err := CompressValue(content, synchronizedDirectory.Compression)
+		if err != nil {
+			return DirectoryManifest{}, false, errors.New(fmt.Sprintf("Error compressing file %s of directory %s: %s", file, synchronizedDirectory.Directory, err.Error()))
+		}
+
+		if synchronizedDirectory.MaxFileSize > 0 && int64(len(value)) > synchronizedDirectory.MaxFileSize {
+			oversized[file] = int64(len(value))
+		}
+		values[file] = value
+	}
+
+	if len(oversized) > 0 {
+		return DirectoryManifest{}, false, errors.New(fmt.Sprintf("The following files of directory %s exceed the max_file_size of %d bytes: %s", synchronizedDirectory.Directory, synchronizedDirectory.MaxFileSize, formatOversizedFiles(oversized)))
+	}
+	fillDiffContent(diffs, values)
+
+	maxTxnSize := int64(maxTxnBytes)
+	if synchronizedDirectory.MaxFileSize > maxTxnSize {
+		maxTxnSize = synchronizedDirectory.MaxFileSize
+	}
+
+	revision, applied, err := ApplyDiffToPrefixIfUnchanged(cli, synchronizedDirectory.KeyPrefix, diffs, prefixManifest.Revision, maxTxnSize)
+	if err != nil || !applied {
+		manifest := DirectoryManifest{}
+		if revision != 0 {
+			manifest = PartialManifest(revision, synchronizedDirectory.Compression)
+		}
+
+		if err != nil {
+			return manifest, false, errors.New(fmt.Sprintf("Error synchronizing changes to key prefix %s: %s", synchronizedDirectory.KeyPrefix, err.Error()))
+		}
+		return manifest, false, nil
+	}
+
+	//Files may have changed since they were hashed so the hashes of what was actually written are kept
+	for file, content := range dirContent {
+		dirHashes[file] = HashContent([]byte(content))
+	}
+
+	return DirectoryManifest{Revision: revision, Compression: synchronizedDirectory.Compression, Files: dirHashes}, true, nil
+}
+
+/*
+If the key prefix is modified while the changes are computed, its whole content is retrieved and the changes are computed again.
+If the synchronization fails after some changes were applied, a partial manifest is returned along with the error.
+*/
+func synchronizeDirectoryToKeyPrefix(cli *client.EtcdClient, synchronizedDirectory SynchronizedDirectory, dirHashes map[string]string, metadataValue string, prefixManifest DirectoryManifest, prefixContent *KeyPrefixContent) (DirectoryManifest, error) {
+	partialManifest := DirectoryManifest{}
+	for attempt := uint64(0); ; attempt++ {
+		manifest, applied, err := applyDirectoryToKeyPrefix(cli, synchronizedDirectory, dirHashes, metadataValue, prefixManifest, prefixContent)
+		if applied {
+			return manifest, nil
+		}
+
+		if manifest.Revision != 0 {
+			partialManifest = manifest
+		}
+
+		if err != nil {
+			return partialManifest, err
+		}
+
+		if attempt >= cli.Retries {
+			return partialManifest, errors.New(fmt.Sprintf("Error synchronizing changes to key prefix %s: it kept being modified during the synchronization", synchronizedDirectory.KeyPrefix))
+		}
+
+		prefixManifest, prefixContent, err = GetKeyPrefixContentManifest(cli, synchronizedDirectory.KeyPrefix, synchronizedDirectory.Compression)
+		if err != nil {
+			return partialManifest, errors.New(fmt.Sprintf("Error getting differential of prefix %s and directory %s: %s", synchronizedDirectory.KeyPrefix, synchronizedDirectory.Directory, err.Error()))
+		}
+	}
+}
+
+/*
+Synchronizes the destination with the source, only transferring the files whose hashes differ.
+Returns the manifest of the key prefix after the synchronization.
+*/
+func synchronizeDirectory(cli *client.EtcdClient, synchronizedDirectory SynchronizedDirectory, stateManifest DirectoryManifest) (DirectoryManifest, error) {
+	dirHashes, metadataValue, err := getSynchronizedDirectoryHashes(synchronizedDirectory)
+	if err != nil {
+		return DirectoryManifest{}, errors.New(fmt.Sprintf("Error getting differential of prefix %s and directory %s: %s", synchronizedDirectory.KeyPrefix, synchronizedDirectory.Directory, err.Error()))
+	}
+
+	prefixManifest, prefixContent, err := GetKeyPrefixManifest(cli, synchronizedDirectory.KeyPrefix, synchronizedDirectory.Compression, synchronizedDirectory.ManifestKey, stateManifest)
+	if err != nil {
+		return DirectoryManifest{}, errors.New(fmt.Sprintf("Error getting differential of prefix %s and directory %s: %s", synchronizedDirectory.KeyPrefix, synchronizedDirectory.Directory, err.Error()))
+	}
+
+	if synchronizedDirectory.Source == "directory" {
+		return synchronizeDirectoryToKeyPrefix(cli, synchronizedDirectory, dirHashes, metadataValue, prefixManifest, prefixContent)
+	}
+
+	return synchronizeKeyPrefixToDirectory(cli, synchronizedDirectory, dirHashes, prefixManifest, prefixContent)
+}
+
+func resourceSynchronizedDirectoryCreate(d *schema.ResourceData, meta interface{}) error {
+	synchronizedDirectory := synchronizedDirectorySchemaToModel(d)
+	cli := meta.(*client.EtcdClient)
+
+	if synchronizedDirectory.Source == "key-prefix" {
+		EnsureDirectoryExists(synchronizedDirectory.Directory, synchronizedDirectory.DirectoryPermission)
+	}
+
+	manifest, err := synchronizeDirectory(cli, synchronizedDirectory, getSynchronizedDirectoryManifest(d))
+	if err != nil {
+		//The companion manifest no longer describes a partially synchronized key prefix
+		if synchronizedDirectory.ManifestKey != "" && manifest.Revision != 0 {
+			PutCompanionManifest(cli, synchronizedDirectory.ManifestKey, manifest)
+		}
+		return err
+	}
+
+	if synchronizedDirectory.ManifestKey != "" {
+		err := PutCompanionManifest(cli, synchronizedDirectory.ManifestKey, manifest)
+		if err != nil {
+			return err
+		}
+	}
+
 	d.SetId(synchronizedDirectory.GetId().Serialize())
+	setSynchronizedDirectoryManifest(d, manifest)
 	return nil
 }
 
@@ -225,21 +653,55 @@ func resourceSynchronizedDirectoryRead(d *schema.ResourceData, meta interface{})
 		return nil
 	}
 
-	dirKeys, dirErr := GetDirectoryContent(synchronizedDirectory.Directory)
+	dirHashes, _, dirErr := getSynchronizedDirectoryHashes(synchronizedDirectory)
 	if dirErr != nil {
 		return errors.New(fmt.Sprintf("Error getting differential of prefix %s and directory %s: %s", synchronizedDirectory.KeyPrefix, synchronizedDirectory.Directory, dirErr.Error()))
 	}
 
-	//input is always defined as source for the direction, but it doesn't matter, we just want to see if the diff is empty
-	diffs, err := DiffPrefixWithInput(cli, synchronizedDirectory.KeyPrefix, dirKeys, synchronizedDirectory.Directory, true)
+	prefixManifest, prefixContent, err := GetKeyPrefixManifest(cli, synchronizedDirectory.KeyPrefix, synchronizedDirectory.Compression, synchronizedDirectory.ManifestKey, getSynchronizedDirectoryManifest(d))
 	if err != nil {
 		return errors.New(fmt.Sprintf("Error getting differential of prefix %s and directory %s: %s", synchronizedDirectory.KeyPrefix, synchronizedDirectory.Directory, err.Error()))
 	}
 
+	prefixFiles := prefixManifest.Files
+	if synchronizedDirectory.Source == "key-prefix" {
+		prefixFiles = getSynchronizedKeyPrefixFiles(synchronizedDirectory, prefixManifest)
+	}
+
+	//Direction doesn't matter, we just want to see if the diff is empty
+	diffs := client.GetKeyDiff(dirHashes, prefixFiles)
 	if !diffs.IsEmpty() {
 		d.SetId("")
+		return nil
 	}
 
+	if synchronizedDirectory.Source == "directory" && prefixContent != nil && len(prefixContent.Uncompressed) > 0 {
+		d.SetId("")
+		return nil
+	}
+
+	if synchronizedDirectory.Source == "key-prefix" && synchronizedDirectory.Metadata.Enabled() {
+		metadata, err := getSynchronizedKeyPrefixMetadata(cli, synchronizedDirectory, prefixContent)
+		if err != nil {
+			return err
+		}
+
+		dirMetadata, err := GetDirectoryMetadata(synchronizedDirectory.Directory, synchronizedDirectory.Metadata, synchronizedDirectory.DirectorySymlinks())
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error getting metadata of files in directory %s: %s", synchronizedDirectory.Directory, err.Error()))
+		}
+
+		//Only the files with stored metadata can have it restored
+		for file, fileMetadata := range metadata {
+			if dirFileMetadata, ok := dirMetadata[file]; ok && dirFileMetadata != fileMetadata {
+				d.SetId("")
+				return nil
+			}
+		}
+	}
+
+	setSynchronizedDirectoryManifest(d, prefixManifest)
+
 	return nil
 }
 
@@ -8,6 +8,8 @@ import (
 	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 func resourceSynchronizedKeyPrefixes() *schema.Resource {
@@ -49,6 +51,26 @@ func resourceSynchronizedKeyPrefixes() *schema.Resource {
 					return []string{}, []error{}
 				},
 			},
+			"source_max_mod_revision": {
+				Description: "Highest modification revision of the keys of the source prefix at the last synchronization. With the onchange recurrence, the prefixes are only compared fully when it or the number of keys changed.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"source_keys_count": {
+				Description: "Number of keys of the source prefix at the last synchronization.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"destination_max_mod_revision": {
+				Description: "Highest modification revision of the keys of the destination prefix after the last synchronization. It is tracked so that changes to the destination are also detected.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"destination_keys_count": {
+				Description: "Number of keys of the destination prefix after the last synchronization.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
 		},
 	}
 }
@@ -96,10 +118,32 @@ func DeserializeSynchronizedKeyPrefixesId(id string) (SynchronizedKeyPrefixesId,
 	return synchronizedKeyPrefixesId, err
 }
 
+func getSynchronizedKeyPrefixesFingerprint(cli *client.EtcdClient, prefix string) (KeyRangeFingerprint, error) {
+	fingerprint, err := QueryKeyRangeFingerprint(cli, prefix, clientv3.GetPrefixRangeEnd(prefix))
+	if err != nil {
+		return fingerprint, errors.New(fmt.Sprintf("Error getting fingerprint of prefix %s: %s", prefix, err.Error()))
+	}
+
+	return fingerprint, nil
+}
+
+func setSynchronizedKeyPrefixesFingerprints(d *schema.ResourceData, source KeyRangeFingerprint, destination KeyRangeFingerprint) {
+	d.Set("source_max_mod_revision", source.MaxModRevision)
+	d.Set("source_keys_count", source.KeysCount)
+	d.Set("destination_max_mod_revision", destination.MaxModRevision)
+	d.Set("destination_keys_count", destination.KeysCount)
+}
+
 func resourceSynchronizedKeyPrefixesCreate(d *schema.ResourceData, meta interface{}) error {
 	synchronizedKeyPrefixes := synchronizedKeyPrefixesSchemaToModel(d)
 	cli := meta.(*client.EtcdClient)
 
+	//Taken before the synchronization so that source changes made during it are detected later on
+	sourceFingerprint, err := getSynchronizedKeyPrefixesFingerprint(cli, synchronizedKeyPrefixes.SourcePrefix)
+	if err != nil {
+		return err
+	}
+
 	diffs, err := cli.DiffBetweenPrefixes(synchronizedKeyPrefixes.SourcePrefix, synchronizedKeyPrefixes.DestinationPrefix)
 	if err != nil {
 		return errors.New(fmt.Sprintf("Error getting differential of prefix %s from prefix %s: %s", synchronizedKeyPrefixes.DestinationPrefix, synchronizedKeyPrefixes.SourcePrefix, err.Error()))
@@ -112,7 +156,13 @@ func resourceSynchronizedKeyPrefixesCreate(d *schema.ResourceData, meta interfac
 		}
 	}
 
+	destinationFingerprint, err := getSynchronizedKeyPrefixesFingerprint(cli, synchronizedKeyPrefixes.DestinationPrefix)
+	if err != nil {
+		return err
+	}
+
 	d.SetId(synchronizedKeyPrefixes.GetId().Serialize())
+	setSynchronizedKeyPrefixesFingerprints(d, sourceFingerprint, destinationFingerprint)
 	return nil
 }
 
@@ -129,6 +179,23 @@ func resourceSynchronizedKeyPrefixesRead(d *schema.ResourceData, meta interface{
 		return nil
 	}
 
+	sourceFingerprint, err := getSynchronizedKeyPrefixesFingerprint(cli, synchronizedKeyPrefixes.SourcePrefix)
+	if err != nil {
+		return err
+	}
+
+	destinationFingerprint, err := getSynchronizedKeyPrefixesFingerprint(cli, synchronizedKeyPrefixes.DestinationPrefix)
+	if err != nil {
+		return err
+	}
+
+	if sourceFingerprint.MaxModRevision == int64(d.Get("source_max_mod_revision").(int)) &&
+		sourceFingerprint.KeysCount == int64(d.Get("source_keys_count").(int)) &&
+		destinationFingerprint.MaxModRevision == int64(d.Get("destination_max_mod_revision").(int)) &&
+		destinationFingerprint.KeysCount == int64(d.Get("destination_keys_count").(int)) {
+		return nil
+	}
+
 	diffs, err := cli.DiffBetweenPrefixes(synchronizedKeyPrefixes.SourcePrefix, synchronizedKeyPrefixes.DestinationPrefix)
 	if err != nil {
 		return errors.New(fmt.Sprintf("Error getting differential of prefix %s from prefix %s: %s", synchronizedKeyPrefixes.DestinationPrefix, synchronizedKeyPrefixes.SourcePrefix, err.Error()))
@@ -136,8 +203,12 @@ func resourceSynchronizedKeyPrefixesRead(d *schema.ResourceData, meta interface{
 
 	if !diffs.IsEmpty() {
 		d.SetId("")
+		return nil
 	}
 
+	//The prefixes are still synchronized so the full comparison can be skipped until they change again
+	setSynchronizedKeyPrefixesFingerprints(d, sourceFingerprint, destinationFingerprint)
+
 	return nil
 }
 
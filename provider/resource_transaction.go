@@ -0,0 +1,323 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func transactionOperationSchema(description string) *schema.Schema {
+	return &schema.Schema{
+		Description: description,
+		Type:        schema.TypeList,
+		Optional:    true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"type": {
+					Description:  "Type of the operation. Can be: put, delete or delete_range",
+					Type:         schema.TypeString,
+					Required:     true,
+					ValidateFunc: validation.StringInSlice([]string{"put", "delete", "delete_range"}, false),
+				},
+				"key": {
+					Description:  "Key to put or delete. For the delete_range operation, beginning of the key range to delete.",
+					Type:         schema.TypeString,
+					Required:     true,
+					ValidateFunc: validation.StringIsNotEmpty,
+				},
+				"value": {
+					Description: "Value to put. Only used by the put operation.",
+					Type:        schema.TypeString,
+					Optional:    true,
+					Default:     "",
+				},
+				"range_end": {
+					Description: "End of the key range to delete (exclusive). Required by the delete_range operation and only used by it.",
+					Type:        schema.TypeString,
+					Optional:    true,
+					Default:     "",
+				},
+			},
+		},
+	}
+}
+
+func resourceTransaction() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Executes an etcd transaction when the resource is created or updated. The success operations are executed if all the comparisons hold and the failure operations are executed otherwise. The transaction is not executed again until one of the arguments changes and nothing is done when the resource is destroyed.",
+		Create:        resourceTransactionUpsert,
+		Read:          resourceTransactionRead,
+		Update:        resourceTransactionUpsert,
+		Delete:        resourceTransactionDelete,
+		CustomizeDiff: resourceTransactionCustomizeDiff,
+		Schema: map[string]*schema.Schema{
+			"compare": {
+				Description: "Comparisons that must all hold for the success operations to be executed. If there are no comparisons, the success operations are executed.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"key": {
+							Description:  "Key to compare. If range_end is set, beginning of the key range whose keys are all compared.",
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.StringIsNotEmpty,
+						},
+						"range_end": {
+							Description: "End of the key range to compare (exclusive). If omitted, only the key is compared.",
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "",
+						},
+						"target": {
+							Description:  "Property of the key to compare. Can be: value, version, create_revision, mod_revision or lease. Note that the version, create_revision and mod_revision of a key that does not exist are 0.",
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.StringInSlice([]string{"value", "version", "create_revision", "mod_revision", "lease"}, false),
+						},
+						"operator": {
+							Description:  "Comparison operator. Can be: =, !=, > or <",
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "=",
+							ValidateFunc: validation.StringInSlice([]string{"=", "!=", ">", "<"}, false),
+						},
+						"value": {
+							Description: "Value to compare the property of the key to. It must be an integer for all targets but value.",
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "",
+						},
+					},
+				},
+			},
+			"success": transactionOperationSchema("Operations to execute if all the comparisons hold."),
+			"failure": transactionOperationSchema("Operations to execute if any of the comparisons does not hold."),
+			"triggers": {
+				Description: "Arbitrary values that cause the transaction to be executed again when they change.",
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"succeeded": {
+				Description: "Whether all the comparisons held when the transaction was executed, in which case the success operations were executed.",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+			"revision": {
+				Description: "Revision of the etcd keystore after the transaction was executed.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+type TransactionCompare struct {
+	Key      string
+	RangeEnd string
+	Target   string
+	Operator string
+	Value    string
+}
+
+func (compare TransactionCompare) ToCmp() (clientv3.Cmp, error) {
+	var cmp clientv3.Cmp
+
+	if compare.Target == "value" {
+		cmp = clientv3.Compare(clientv3.Value(compare.Key), compare.Operator, compare.Value)
+	} else {
+		number, err := strconv.ParseInt(compare.Value, 10, 64)
+		if err != nil {
+			return cmp, errors.New(fmt.Sprintf("Value '%s' compared to the %s of key '%s' must be an integer", compare.Value, compare.Target, compare.Key))
+		}
+
+		switch compare.Target {
+		case "version":
+			cmp = clientv3.Compare(clientv3.Version(compare.Key), compare.Operator, number)
+		case "create_revision":
+			cmp = clientv3.Compare(clientv3.CreateRevision(compare.Key), compare.Operator, number)
+		case "mod_revision":
+			cmp = clientv3.Compare(clientv3.ModRevision(compare.Key), compare.Operator, number)
+		case "lease":
+			cmp = clientv3.Compare(clientv3.LeaseValue(compare.Key), compare.Operator, number)
+		}
+	}
+
+	if compare.RangeEnd != "" {
+		cmp = cmp.WithRange(compare.RangeEnd)
+	}
+
+	return cmp, nil
+}
+
+type TransactionOperation struct {
+	Type     string
+	Key      string
+	Value    string
+	RangeEnd string
+}
+
+func (operation TransactionOperation) ToOp() (clientv3.Op, error) {
+	switch operation.Type {
+	case "put":
+		return clientv3.OpPut(operation.Key, operation.Value), nil
+	case "delete":
+		return clientv3.OpDelete(operation.Key), nil
+	default:
+		if operation.RangeEnd == "" {
+			return clientv3.Op{}, errors.New(fmt.Sprintf("The delete_range operation on key '%s' requires a range_end", operation.Key))
+		}
+		return clientv3.OpDelete(operation.Key, clientv3.WithRange(operation.RangeEnd)), nil
+	}
+}
+
+type Transaction struct {
+	Compares []TransactionCompare
+	Success  []TransactionOperation
+	Failure  []TransactionOperation
+}
+
+func transactionOperationsSchemaToModel(operations []interface{}) []TransactionOperation {
+	model := []TransactionOperation{}
+	for _, val := range operations {
+		operation := val.(map[string]interface{})
+		model = append(model, TransactionOperation{
+			Type:     operation["type"].(string),
+			Key:      operation["key"].(string),
+			Value:    operation["value"].(string),
+			RangeEnd: operation["range_end"].(string),
+		})
+	}
+	return model
+}
+
+func transactionSchemaToModel(d *schema.ResourceData) Transaction {
+	model := Transaction{Compares: []TransactionCompare{}}
+
+	for _, val := range d.Get("compare").([]interface{}) {
+		compare := val.(map[string]interface{})
+		model.Compares = append(model.Compares, TransactionCompare{
+			Key:      compare["key"].(string),
+			RangeEnd: compare["range_end"].(string),
+			Target:   compare["target"].(string),
+			Operator: compare["operator"].(string),
+			Value:    compare["value"].(string),
+		})
+	}
+
+	model.Success = transactionOperationsSchemaToModel(d.Get("success").([]interface{}))
+	model.Failure = transactionOperationsSchemaToModel(d.Get("failure").([]interface{}))
+
+	return model
+}
+
+func (txn Transaction) build() ([]clientv3.Cmp, []clientv3.Op, []clientv3.Op, error) {
+	cmps := []clientv3.Cmp{}
+	for _, compare := range txn.Compares {
+		cmp, err := compare.ToCmp()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		cmps = append(cmps, cmp)
+	}
+
+	opLists := [][]clientv3.Op{[]clientv3.Op{}, []clientv3.Op{}}
+	for idx, operations := range [][]TransactionOperation{txn.Success, txn.Failure} {
+		for _, operation := range operations {
+			op, err := operation.ToOp()
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			opLists[idx] = append(opLists[idx], op)
+		}
+	}
+
+	return cmps, opLists[0], opLists[1], nil
+}
+
+/*
+Executes the transaction and returns whether it succeeded along with the revision of the store after it.
+It is not retried as it may not be idempotent.
+*/
+func (txn Transaction) Execute(cli *client.EtcdClient) (bool, int64, error) {
+	cmps, success, failure, err := txn.build()
+	if err != nil {
+		return false, 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(cli.Context, cli.RequestTimeout)
+	defer cancel()
+
+	res, err := cli.Client.Txn(ctx).If(cmps...).Then(success...).Else(failure...).Commit()
+	if err != nil {
+		return false, 0, errors.New(fmt.Sprintf("Error executing transaction: %s", err.Error()))
+	}
+
+	return res.Succeeded, res.Header.Revision, nil
+}
+
+//Values that are not known yet are validated when the transaction is executed
+func resourceTransactionCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	for idx, val := range d.Get("compare").([]interface{}) {
+		compare := val.(map[string]interface{})
+		valuePath := fmt.Sprintf("compare.%d.value", idx)
+		if compare["target"].(string) != "value" && d.NewValueKnown(valuePath) {
+			if _, err := strconv.ParseInt(compare["value"].(string), 10, 64); err != nil {
+				return errors.New(fmt.Sprintf("Value '%s' compared to the %s of key '%s' must be an integer", compare["value"].(string), compare["target"].(string), compare["key"].(string)))
+			}
+		}
+	}
+
+	for _, operations := range []string{"success", "failure"} {
+		for idx, val := range d.Get(operations).([]interface{}) {
+			operation := val.(map[string]interface{})
+			rangeEndPath := fmt.Sprintf("%s.%d.range_end", operations, idx)
+			if operation["type"].(string) == "delete_range" && d.NewValueKnown(rangeEndPath) && operation["range_end"].(string) == "" {
+				return errors.New(fmt.Sprintf("The delete_range operation on key '%s' requires a range_end", operation["key"].(string)))
+			}
+		}
+	}
+
+	if d.HasChanges("compare", "success", "failure", "triggers") {
+		d.SetNewComputed("succeeded")
+		d.SetNewComputed("revision")
+	}
+
+	return nil
+}
+
+func resourceTransactionUpsert(d *schema.ResourceData, meta interface{}) error {
+	cli := meta.(*client.EtcdClient)
+	txn := transactionSchemaToModel(d)
+
+	succeeded, revision, err := txn.Execute(cli)
+	if err != nil {
+		return err
+	}
+
+	if d.Id() == "" {
+		d.SetId(strconv.FormatInt(revision, 10))
+	}
+	d.Set("succeeded", succeeded)
+	d.Set("revision", revision)
+
+	return nil
+}
+
+func resourceTransactionRead(d *schema.ResourceData, meta interface{}) error {
+	return nil
+}
+
+func resourceTransactionDelete(d *schema.ResourceData, meta interface{}) error {
+	return nil
+}
@@ -1,12 +1,18 @@
 package provider
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"slices"
 
 	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
+	"github.com/hashicorp/go-cty/cty"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 func resourceUser() *schema.Resource {
@@ -28,12 +34,48 @@ func resourceUser() *schema.Resource {
 				ValidateFunc: validation.StringIsNotEmpty,
 			},
 			"password": {
-				Description:  "Password of the user. Can be omitted for a user that you wish to authenticate strictly with tls certificate authentication.",
-				Type:         schema.TypeString,
-				Sensitive:    true,
-				Optional:     true,
-				ForceNew:     false,
-				ValidateFunc: validation.StringIsNotEmpty,
+				Description:   "Password of the user. Can be omitted for a user that you wish to authenticate strictly with tls certificate authentication.",
+				Type:          schema.TypeString,
+				Sensitive:     true,
+				Optional:      true,
+				ForceNew:      false,
+				ConflictsWith: []string{"password_wo"},
+				ValidateFunc:  validation.StringIsNotEmpty,
+			},
+			"password_wo": {
+				Description:   "Write-only alternative to the password argument that is never stored in the terraform state. Given that terraform cannot detect changes to it, the password_version argument should be changed whenever the password is changed. Requires terraform 1.11 or later.",
+				Type:          schema.TypeString,
+				Sensitive:     true,
+				WriteOnly:     true,
+				Optional:      true,
+				ConflictsWith: []string{"password"},
+				ValidateFunc:  validation.StringIsNotEmpty,
+			},
+			"password_version": {
+				Description: "Arbitrary version of the password_wo argument. Changing it will update the password of the user to the current value of password_wo.",
+				Type:        schema.TypeInt,
+				Optional:    true,
+				ForceNew:    false,
+			},
+			"verify_password": {
+				Description: "Whether to verify, when the resource is refreshed, that the user can still authenticate with the password argument by attempting a test authentication. If the authentication fails, the password will be reset on the next apply. Has no effect with the password_wo argument or if authentication is not enabled on the etcd cluster.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				ForceNew:    false,
+			},
+			"no_password": {
+				Description:   "Whether to create the user without a password so that it can only authenticate with tls certificate authentication. Changing this will delete the user and create a new one.",
+				Type:          schema.TypeBool,
+				Optional:      true,
+				Default:       false,
+				ForceNew:      true,
+				ConflictsWith: []string{"password", "password_wo"},
+			},
+			"auth_mode": {
+				Description: "Authentication mode of the user. Can be: password (the user was created with a password, possibly blank), no-password (the user can only authenticate with a tls certificate) or unknown. It is set from the no_password argument when the user is created. As etcd only reports it through authentication attempts, it is inferred once for imported users by attempting to authenticate with an empty password. It is unknown if authentication was not enabled on the etcd cluster then or if the attempt failed unexpectedly, in which case it is inferred again on the next refresh.",
+				Type:        schema.TypeString,
+				Computed:    true,
 			},
 			"roles": {
 				Description: "Roles of the user, to define his access.",
@@ -59,6 +101,11 @@ func userSchemaToModel(d *schema.ResourceData) client.EtcdUser {
 		model.Password = password.(string)
 	}
 
+	passwordWo, _ := d.GetRawConfigAt(cty.GetAttrPath("password_wo"))
+	if passwordWo.Type().Equals(cty.String) && passwordWo.IsKnown() && !passwordWo.IsNull() {
+		model.Password = passwordWo.AsString()
+	}
+
 	roles, rolesExist := d.GetOk("roles")
 	if rolesExist {
 		for _, val := range (roles.(*schema.Set)).List() {
@@ -70,16 +117,46 @@ func userSchemaToModel(d *schema.ResourceData) client.EtcdUser {
 	return model
 }
 
+func insertNoPasswordUser(cli *client.EtcdClient, user client.EtcdUser) error {
+	ctx, cancel := context.WithTimeout(cli.Context, cli.RequestTimeout)
+	defer cancel()
+
+	_, err := cli.Client.UserAddWithOptions(ctx, user.Username, "", &clientv3.UserAddOptions{NoPassword: true})
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error creating new user '%s': %s", user.Username, err.Error()))
+	}
+
+	for _, role := range user.Roles {
+		err := cli.GrantUserRole(user.Username, role)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error adding role '%s' to user '%s': %s", role, user.Username, err.Error()))
+		}
+	}
+
+	return nil
+}
+
 func resourceUserCreate(d *schema.ResourceData, meta interface{}) error {
 	user := userSchemaToModel(d)
 	cli := meta.(*client.EtcdClient)
 
-	err := cli.UpsertUser(user)
+	var err error
+	if d.Get("no_password").(bool) {
+		err = insertNoPasswordUser(cli, user)
+	} else {
+		err = cli.UpsertUser(user)
+	}
 	if err != nil {
 		return err
 	}
 
 	d.SetId(user.Username)
+	if d.Get("no_password").(bool) {
+		d.Set("auth_mode", "no-password")
+	} else {
+		d.Set("auth_mode", "password")
+	}
+
 	return resourceUserRead(d, meta)
 }
 
@@ -100,6 +177,109 @@ func resourceUserRead(d *schema.ResourceData, meta interface{}) error {
 	d.Set("username", username)
 	d.Set("roles", resRoles)
 
+	//The authentication mode cannot change without recreating the user, so it is only inferred for imported users
+	authMode := d.Get("auth_mode").(string)
+	if authMode == "" || authMode == "unknown" {
+		authMode = getUserAuthMode(cli, username)
+		d.Set("auth_mode", authMode)
+		if authMode != "unknown" {
+			d.Set("no_password", authMode == "no-password")
+		}
+	}
+
+	password := d.Get("password").(string)
+	if d.Get("verify_password").(bool) && password != "" {
+		valid, verifyErr := verifyUserPassword(cli, username, password)
+		if verifyErr != nil {
+			return errors.New(fmt.Sprintf("Error verifying password of user '%s': %s", username, verifyErr.Error()))
+		}
+
+		if !valid {
+			d.Set("password", "")
+		}
+	}
+
+	return nil
+}
+
+/*
+Attempts to authenticate as the user to determine if the password is valid.
+The password is assumed valid if authentication is not enabled as it cannot be verified.
+*/
+func verifyUserPassword(cli *client.EtcdClient, username string, password string) (bool, error) {
+	ctx, cancel := context.WithTimeout(cli.Context, cli.RequestTimeout)
+	defer cancel()
+
+	_, err := cli.Client.Authenticate(ctx, username, password)
+	if err != nil {
+		if err == rpctypes.ErrAuthFailed {
+			return false, nil
+		}
+
+		if err == rpctypes.ErrAuthNotEnabled {
+			return true, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+/*
+Message of the auth.ErrNoPasswordUser error etcd (as of 3.5.21) returns when authenticating as a user without a password.
+It is not mapped to a grpc error by the server which sends it with the Unknown code, so it has no counterpart in rpctypes.
+*/
+const noPasswordUserErrorDesc = "auth: authentication failed, password was given for no password user"
+
+/*
+Etcd does not report whether a user has a password so it is inferred from the outcome of an authentication attempt with an empty password.
+Users without a password fail with a specific error.
+The mode is unknown if authentication is not enabled or if the attempt fails for any other reason.
+*/
+func getUserAuthMode(cli *client.EtcdClient, username string) string {
+	ctx, cancel := context.WithTimeout(cli.Context, cli.RequestTimeout)
+	defer cancel()
+
+	_, err := cli.Client.Authenticate(ctx, username, "")
+	if err == nil || err == rpctypes.ErrAuthFailed {
+		return "password"
+	}
+
+	if rpctypes.ErrorDesc(err) == noPasswordUserErrorDesc {
+		return "no-password"
+	}
+
+	return "unknown"
+}
+
+/*
+Only the roles of a user without a password are updated, as changing the password of the user, even to an empty one, would give it a password.
+*/
+func updateNoPasswordUserRoles(cli *client.EtcdClient, user client.EtcdUser) error {
+	resRoles, _, userRolesErr := cli.GetUserRoles(user.Username)
+	if userRolesErr != nil {
+		return errors.New(fmt.Sprintf("Error retrieving existing user '%s' for update: %s", user.Username, userRolesErr.Error()))
+	}
+
+	for _, role := range resRoles {
+		if !slices.Contains(user.Roles, role) {
+			err := cli.RevokeUserRole(user.Username, role)
+			if err != nil {
+				return errors.New(fmt.Sprintf("Error removing role '%s' from user '%s': %s", role, user.Username, err.Error()))
+			}
+		}
+	}
+
+	for _, role := range user.Roles {
+		if !slices.Contains(resRoles, role) {
+			err := cli.GrantUserRole(user.Username, role)
+			if err != nil {
+				return errors.New(fmt.Sprintf("Error adding role '%s' to user '%s': %s", role, user.Username, err.Error()))
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -107,7 +287,12 @@ func resourceUserUpdate(d *schema.ResourceData, meta interface{}) error {
 	user := userSchemaToModel(d)
 	cli := meta.(*client.EtcdClient)
 
-	err := cli.UpsertUser(user)
+	var err error
+	if d.Get("no_password").(bool) {
+		err = updateNoPasswordUserRoles(cli, user)
+	} else {
+		err = cli.UpsertUser(user)
+	}
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,127 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func resourceUserRoleBinding() *schema.Resource {
+	return &schema.Resource{
+		Description: "Grants a single role to a user without affecting the other roles of the user. Useful when several terraform projects need to grant roles to the same user. It should not be used on a user whose roles are managed by an etcd_user resource as the two would conflict. A user created with an etcd_user resource can still be used if that resource ignores changes to its roles with the ignore_changes lifecycle argument.",
+		Create:      resourceUserRoleBindingCreate,
+		Read:        resourceUserRoleBindingRead,
+		Delete:      resourceUserRoleBindingDelete,
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
+		Schema: map[string]*schema.Schema{
+			"username": {
+				Description:  "Name of the user to grant the role to.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"role": {
+				Description:  "Name of the role to grant to the user.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+		},
+	}
+}
+
+type UserRoleBindingId struct {
+	Username string
+	Role     string
+}
+
+//Needed to absolutely ensure it is deterministic
+func (id UserRoleBindingId) MarshalJSON() ([]byte, error) {
+	mUsername, _ := json.Marshal(id.Username)
+	mRole, _ := json.Marshal(id.Role)
+	return []byte(fmt.Sprintf("{\"Username\":%s,\"Role\":%s}", string(mUsername), string(mRole))), nil
+}
+
+func (id UserRoleBindingId) Serialize() string {
+	out, _ := json.Marshal(id)
+	return string(out)
+}
+
+func DeserializeUserRoleBindingId(id string) (UserRoleBindingId, error) {
+	var userRoleBindingId UserRoleBindingId
+	err := json.Unmarshal([]byte(id), &userRoleBindingId)
+	return userRoleBindingId, err
+}
+
+func userRoleBindingSchemaToModel(d *schema.ResourceData) UserRoleBindingId {
+	return UserRoleBindingId{
+		Username: d.Get("username").(string),
+		Role:     d.Get("role").(string),
+	}
+}
+
+func resourceUserRoleBindingCreate(d *schema.ResourceData, meta interface{}) error {
+	binding := userRoleBindingSchemaToModel(d)
+	cli := meta.(*client.EtcdClient)
+
+	err := cli.GrantUserRole(binding.Username, binding.Role)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error adding role '%s' to user '%s': %s", binding.Role, binding.Username, err.Error()))
+	}
+
+	d.SetId(binding.Serialize())
+	return resourceUserRoleBindingRead(d, meta)
+}
+
+func resourceUserRoleBindingRead(d *schema.ResourceData, meta interface{}) error {
+	binding, idErr := DeserializeUserRoleBindingId(d.Id())
+	if idErr != nil {
+		return errors.New(fmt.Sprintf("Error parsing user role binding id '%s': %s", d.Id(), idErr.Error()))
+	}
+	cli := meta.(*client.EtcdClient)
+
+	resRoles, userExists, err := cli.GetUserRoles(binding.Username)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error retrieving existing user '%s' for reading: %s", binding.Username, err.Error()))
+	}
+
+	if !userExists || !slices.Contains(resRoles, binding.Role) {
+		d.SetId("")
+		return nil
+	}
+
+	d.Set("username", binding.Username)
+	d.Set("role", binding.Role)
+
+	return nil
+}
+
+func resourceUserRoleBindingDelete(d *schema.ResourceData, meta interface{}) error {
+	binding := userRoleBindingSchemaToModel(d)
+	cli := meta.(*client.EtcdClient)
+
+	resRoles, userExists, err := cli.GetUserRoles(binding.Username)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error retrieving existing user '%s' for deletion: %s", binding.Username, err.Error()))
+	}
+
+	if !userExists || !slices.Contains(resRoles, binding.Role) {
+		return nil
+	}
+
+	err = cli.RevokeUserRole(binding.Username, binding.Role)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error removing role '%s' from user '%s': %s", binding.Role, binding.Username, err.Error()))
+	}
+
+	return nil
+}
@@ -0,0 +1,241 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func resourceWait() *schema.Resource {
+	return &schema.Resource{
+		Description: "Waits when created until a condition holds on a key or key range, using a watch. Useful for bootstrapping workflows that must wait for an application to publish a key. The wait only happens when the resource is created.",
+		Create:      resourceWaitCreate,
+		Read:        resourceWaitRead,
+		Delete:      resourceWaitDelete,
+		CustomizeDiff: func(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+			if d.Get("condition").(string) == "matches" && d.NewValueKnown("regex") && d.Get("regex").(string) == "" {
+				return errors.New("The regex argument is required when the condition is matches")
+			}
+			return nil
+		},
+		Schema: map[string]*schema.Schema{
+			"key": {
+				Description:  "Key to wait on or beginning of the key range to wait on.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"range_end": {
+				Description: "End of the key range to wait on (exclusive). If omitted, only the key is waited on. For a key range, the exists, equals and matches conditions hold when any key of the range satisfies them and the absent condition holds when the range has no keys.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     "",
+			},
+			"condition": {
+				Description:  "Condition to wait for. Can be: exists, equals (the value is equal to the value argument), matches (the value matches the regex argument) or absent.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice([]string{"exists", "equals", "matches", "absent"}, false),
+			},
+			"value": {
+				Description: "Value to wait for when the condition is equals.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     "",
+			},
+			"regex": {
+				Description:  "Regex that the value must match when the condition is matches.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      "",
+				ValidateFunc: validation.StringIsValidRegExp,
+			},
+			"timeout": {
+				Description:  "Maximum duration to wait for the condition before failing. Defaults to 5m.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      "5m",
+				ValidateFunc: validateDuration,
+			},
+			"observed_key": {
+				Description: "Key that satisfied the condition. It is empty if the condition is absent.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"observed_value": {
+				Description: "Value of the key that satisfied the condition. It is empty if the condition is absent.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"observed_revision": {
+				Description: "Revision of the etcd keystore at which the condition was observed to hold.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+type WaitCondition struct {
+	Condition string
+	Value     string
+	Regex     *regexp.Regexp
+}
+
+/*
+Returns whether the condition holds on the keys and, if it does, the key that satisfies it.
+Keys are evaluated in order so that the result is deterministic.
+*/
+func (cond WaitCondition) Evaluate(keys client.KeyInfoMap) (client.KeyInfo, bool) {
+	if cond.Condition == "absent" {
+		return client.KeyInfo{}, len(keys) == 0
+	}
+
+	for _, keyInfo := range SortKeyInfos(keys) {
+		switch cond.Condition {
+		case "exists":
+			return keyInfo, true
+		case "equals":
+			if keyInfo.Value == cond.Value {
+				return keyInfo, true
+			}
+		case "matches":
+			if cond.Regex.MatchString(keyInfo.Value) {
+				return keyInfo, true
+			}
+		}
+	}
+
+	return client.KeyInfo{}, false
+}
+
+func watchOptions(rangeEnd string, revision int64) []clientv3.OpOption {
+	opts := []clientv3.OpOption{clientv3.WithRev(revision)}
+	if rangeEnd != "" {
+		opts = append(opts, clientv3.WithRange(rangeEnd))
+	}
+	return opts
+}
+
+/*
+Updates the keys with the events of a watch starting right after their revision until the condition holds.
+Returns false if the watch ends before the condition holds.
+*/
+func watchForCondition(ctx context.Context, cli *client.EtcdClient, key string, rangeEnd string, cond WaitCondition, keys client.KeyRangeInfo) (client.KeyInfo, int64, bool) {
+	watchCtx, cancel := context.WithCancel(clientv3.WithRequireLeader(ctx))
+	defer cancel()
+
+	wc := cli.Client.Watch(watchCtx, key, watchOptions(rangeEnd, keys.Revision+1)...)
+	for res := range wc {
+		if res.Err() != nil {
+			return client.KeyInfo{}, 0, false
+		}
+
+		for _, ev := range res.Events {
+			if ev.Type == clientv3.EventTypeDelete {
+				delete(keys.Keys, string(ev.Kv.Key))
+				continue
+			}
+			keys.Keys[string(ev.Kv.Key)] = keyValueToKeyInfo(ev.Kv)
+		}
+
+		observed, holds := cond.Evaluate(keys.Keys)
+		if holds {
+			return observed, res.Header.Revision, true
+		}
+	}
+
+	return client.KeyInfo{}, 0, false
+}
+
+/*
+Waits until the condition holds on the key or key range.
+The keys are read once and then kept up to date with a watch starting right after the revision they were read at.
+If the watch is interrupted, the keys are read again.
+Returns the key satisfying the condition and the revision at which the condition held.
+*/
+func WaitForCondition(cli *client.EtcdClient, key string, rangeEnd string, cond WaitCondition, timeout time.Duration) (client.KeyInfo, int64, error) {
+	ctx, cancel := context.WithTimeout(cli.Context, timeout)
+	defer cancel()
+
+	readRangeEnd := rangeEnd
+	if readRangeEnd == "" {
+		readRangeEnd = key + "\x00"
+	}
+
+	for {
+		keys, err := cli.GetKeyRange(key, readRangeEnd)
+		if err != nil {
+			return client.KeyInfo{}, 0, err
+		}
+
+		observed, holds := cond.Evaluate(keys.Keys)
+		if holds {
+			return observed, keys.Revision, nil
+		}
+
+		observed, revision, holds := watchForCondition(ctx, cli, key, rangeEnd, cond, keys)
+		if holds {
+			return observed, revision, nil
+		}
+
+		if ctx.Err() != nil {
+			return client.KeyInfo{}, 0, errors.New(fmt.Sprintf("Timed out after %s waiting for condition '%s' on key '%s'", timeout.String(), cond.Condition, key))
+		}
+
+		time.Sleep(cli.RetryInterval)
+	}
+}
+
+func resourceWaitCreate(d *schema.ResourceData, meta interface{}) error {
+	cli := meta.(*client.EtcdClient)
+	key := d.Get("key").(string)
+	rangeEnd := d.Get("range_end").(string)
+	timeout, _ := time.ParseDuration(d.Get("timeout").(string))
+
+	cond := WaitCondition{
+		Condition: d.Get("condition").(string),
+		Value:     d.Get("value").(string),
+	}
+	if cond.Condition == "matches" {
+		regex, err := regexp.Compile(d.Get("regex").(string))
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error parsing regex: %s", err.Error()))
+		}
+		cond.Regex = regex
+	}
+
+	observed, revision, err := WaitForCondition(cli, key, rangeEnd, cond, timeout)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(KeyRangeId{key, rangeEnd}.Serialize())
+	d.Set("observed_key", observed.Key)
+	d.Set("observed_value", observed.Value)
+	d.Set("observed_revision", revision)
+
+	return nil
+}
+
+func resourceWaitRead(d *schema.ResourceData, meta interface{}) error {
+	return nil
+}
+
+func resourceWaitDelete(d *schema.ResourceData, meta interface{}) error {
+	return nil
+}
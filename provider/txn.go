@@ -0,0 +1,32 @@
+package provider
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ferlab-Ste-Justine/etcd-sdk/client"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+/*
+Commits a transaction executing the operations if all the comparisons hold, retrying on retryable errors.
+The operations must be idempotent as a transaction that timed out may have been applied before it is retried.
+Whether the comparisons held is reported by the Succeeded property of the response.
+*/
+func commitTxnWithRetries(cli *client.EtcdClient, cmps []clientv3.Cmp, ops []clientv3.Op, retries uint64) (*clientv3.TxnResponse, error) {
+	ctx, cancel := context.WithTimeout(cli.Context, cli.RequestTimeout)
+	defer cancel()
+
+	res, err := cli.Client.Txn(ctx).If(cmps...).Then(ops...).Commit()
+	if err != nil {
+		if retries == 0 || !client.ErrorIsRetryable(err) {
+			return nil, err
+		}
+
+		time.Sleep(cli.RetryInterval)
+		return commitTxnWithRetries(cli, cmps, ops, retries-1)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,95 @@
+package provider
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+//Compressed values are prefixed with a marker naming their compression, like the values of symbolic links
+func compressionValuePrefix(compression string) string {
+	return "\x00" + compression + ":"
+}
+
+var zstdEncoder, _ = zstd.NewWriter(nil)
+var zstdDecoder, _ = zstd.NewReader(nil)
+
+func validateCompression(val interface{}, key string) (warns []string, errs []error) {
+	compression := val.(string)
+	if compression != "none" && compression != "gzip" && compression != "zstd" {
+		return []string{}, []error{errors.New("The compression field must be one of the following: none, gzip, zstd")}
+	}
+	return []string{}, []error{}
+}
+
+//Compresses a value with the given compression (none, gzip or zstd)
+func CompressValue(value string, compression string) (string, error) {
+	switch compression {
+	case "gzip":
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write([]byte(value)); err != nil {
+			return "", err
+		}
+		if err := writer.Close(); err != nil {
+			return "", err
+		}
+		return compressionValuePrefix(compression) + buf.String(), nil
+	case "zstd":
+		return compressionValuePrefix(compression) + string(zstdEncoder.EncodeAll([]byte(value), nil)), nil
+	default:
+		return value, nil
+	}
+}
+
+func decompressValueContent(content string, compression string) (string, error) {
+	switch compression {
+	case "gzip":
+		reader, err := gzip.NewReader(strings.NewReader(content))
+		if err != nil {
+			return "", errors.New(fmt.Sprintf("Error decompressing gzip value: %s", err.Error()))
+		}
+		defer reader.Close()
+
+		decompressed, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return "", errors.New(fmt.Sprintf("Error decompressing gzip value: %s", err.Error()))
+		}
+		return string(decompressed), nil
+	default:
+		decompressed, err := zstdDecoder.DecodeAll([]byte(content), nil)
+		if err != nil {
+			return "", errors.New(fmt.Sprintf("Error decompressing zstd value: %s", err.Error()))
+		}
+		return string(decompressed), nil
+	}
+}
+
+/*
+Decompresses a value according to the compression named by its marker, if any.
+Returns false if the value was not stored with the given compression (none, gzip or zstd) so that it can be stored again.
+Without compression, values are taken as is, as the content of a file could start with a marker, like with preserved symbolic links.
+*/
+func DecompressValue(value string, compression string) (string, bool, error) {
+	if compression == "none" {
+		return value, true, nil
+	}
+
+	for _, valueCompression := range []string{"gzip", "zstd"} {
+		prefix := compressionValuePrefix(valueCompression)
+		if strings.HasPrefix(value, prefix) {
+			decompressed, err := decompressValueContent(strings.TrimPrefix(value, prefix), valueCompression)
+			if err != nil {
+				return "", false, err
+			}
+			return decompressed, valueCompression == compression, nil
+		}
+	}
+
+	return value, false, nil
+}
@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+func validateValueFormat(val interface{}, key string) (warns []string, errs []error) {
+	format := val.(string)
+	if format != "raw" && format != "json" && format != "yaml" {
+		return []string{}, []error{errors.New("The value_format field must be one of the following: raw, json, yaml")}
+	}
+	return []string{}, []error{}
+}
+
+//Yaml mappings can have non-string keys which json cannot represent
+func yamlToJsonCompatible(val interface{}) interface{} {
+	switch typedVal := val.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{})
+		for k, v := range typedVal {
+			res[k] = yamlToJsonCompatible(v)
+		}
+		return res
+	case map[interface{}]interface{}:
+		res := make(map[string]interface{})
+		for k, v := range typedVal {
+			res[fmt.Sprintf("%v", k)] = yamlToJsonCompatible(v)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(typedVal))
+		for idx, v := range typedVal {
+			res[idx] = yamlToJsonCompatible(v)
+		}
+		return res
+	default:
+		return val
+	}
+}
+
+/*
+Decodes the value of a key in the given format (raw, json or yaml) and returns the result as normalized json.
+A raw value is returned as a json string.
+*/
+func DecodeKeyValue(key string, value string, format string) (string, error) {
+	var decoded interface{}
+
+	switch format {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader([]byte(value)))
+		dec.UseNumber()
+		err := dec.Decode(&decoded)
+		if err == nil && dec.More() {
+			err = errors.New("unexpected content after the json document")
+		}
+		if err != nil {
+			return "", errors.New(fmt.Sprintf("Value of key '%s' is not valid json: %s", key, err.Error()))
+		}
+	case "yaml":
+		err := yaml.Unmarshal([]byte(value), &decoded)
+		if err != nil {
+			return "", errors.New(fmt.Sprintf("Value of key '%s' is not valid yaml: %s", key, err.Error()))
+		}
+		decoded = yamlToJsonCompatible(decoded)
+	default:
+		decoded = value
+	}
+
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(decoded)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("Value of key '%s' could not be converted to json: %s", key, err.Error()))
+	}
+
+	return string(bytes.TrimSuffix(buf.Bytes(), []byte("\n"))), nil
+}
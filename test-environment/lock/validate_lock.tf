@@ -0,0 +1,11 @@
+resource "etcd_lock" "test" {
+    name = "/test_locks/resource"
+    ttl = 10
+    timeout = "1m"
+}
+
+resource "etcd_key" "test_lock" {
+    key = "/test_lock"
+    value = "locked"
+    depends_on = [etcd_lock.test]
+}
@@ -0,0 +1,5 @@
+variable "skip_tls" {
+  description = "Skip tls or not"
+  type = bool
+  default = false
+}
\ No newline at end of file
@@ -1,3 +1,12 @@
 resource "etcd_auth" "cluster_auth" {
     enabled = true
-}
\ No newline at end of file
+}
+
+resource "etcd_auth" "cluster_auth_disabled_on_destroy" {
+    enabled = true
+    on_destroy = "disable"
+}
+
+output "auth_token_type" {
+  value = etcd_auth.cluster_auth.token_type
+}
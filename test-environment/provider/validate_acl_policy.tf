@@ -0,0 +1,61 @@
+resource "etcd_acl_policy" "test" {
+    mode = "authoritative"
+    policy = yamlencode({
+        roles = [
+            {
+                name = "test_policy"
+                permissions = [
+                    {
+                        permission = "readwrite"
+                        key = "/test_policy/"
+                        prefix = true
+                    },
+                    {
+                        permission = "read"
+                        key = "/test_policy_shared"
+                        range_end = "/test_policy_shared"
+                    }
+                ]
+            }
+        ]
+        users = [
+            {
+                username = "test_policy"
+                roles = ["test_policy"]
+                no_password = true
+            }
+        ]
+    })
+}
+
+resource "etcd_user" "test_policy_additive" {
+    username = "test_policy_additive"
+    no_password = true
+
+    lifecycle {
+        ignore_changes = [roles]
+    }
+}
+
+resource "etcd_acl_policy" "test_additive" {
+    policy = jsonencode({
+        roles = [
+            {
+                name = "test_policy_additive"
+                permissions = [
+                    {
+                        permission = "read"
+                        key = "/test_policy_additive/"
+                        prefix = true
+                    }
+                ]
+            }
+        ]
+        users = [
+            {
+                username = etcd_user.test_policy_additive.username
+                roles = ["test_policy_additive"]
+            }
+        ]
+    })
+}
@@ -0,0 +1,13 @@
+resource "etcd_key" "test_election_candidate" {
+    key = "/test_election/candidate1"
+    value = "instance1"
+}
+
+data "etcd_election_leader" "test" {
+    prefix = "/test_election"
+    depends_on = [etcd_key.test_election_candidate]
+}
+
+output "election_leader" {
+  value = data.etcd_election_leader.test
+}
@@ -20,4 +20,27 @@ data "etcd_key" "non_existing_read" {
 
 output "non_existing_read" {
   value     = data.etcd_key.non_existing_read
-}
\ No newline at end of file
+}
+data "etcd_key" "key_to_read_at_revision" {
+    key = "/key/to/read"
+    revision = data.etcd_key.key_to_read.header_revision
+    must_exist = false
+}
+
+output "key_to_read_at_revision" {
+  value     = data.etcd_key.key_to_read_at_revision
+}
+
+resource "etcd_key" "json_key_to_read" {
+    key = "/key/to/read.json"
+    value = jsonencode({ hello = "world" })
+}
+
+data "etcd_key" "json_key_to_read" {
+    key = etcd_key.json_key_to_read.key
+    value_format = "json"
+}
+
+output "json_key_to_read" {
+  value     = jsondecode(data.etcd_key.json_key_to_read.decoded)
+}
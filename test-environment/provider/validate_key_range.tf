@@ -29,4 +29,21 @@ data "etcd_key_range" "test_range" {
 
 output "test_range" {
   value     = data.etcd_key_range.test_range
-}
\ No newline at end of file
+}
+data "etcd_key_range" "test_range_filtered" {
+    key = data.etcd_prefix_range_end.test.key
+    range_end = data.etcd_prefix_range_end.test.range_end
+    key_regex = "^/test/hello[0-9]+$"
+    sort_order = "descend"
+    keys_only = true
+    limit = 1
+    depends_on = [
+        etcd_key.test,
+        etcd_key.test2,
+        etcd_key.test3
+    ]
+}
+
+output "test_range_filtered" {
+  value     = data.etcd_key_range.test_range_filtered
+}
@@ -0,0 +1,16 @@
+resource "etcd_key_range_export" "test" {
+    key = data.etcd_prefix_range_end.test.key
+    range_end = data.etcd_prefix_range_end.test.range_end
+    file = "${path.module}/key-range-export.jsonl"
+    format = "jsonl"
+    clear_on_deletion = true
+    depends_on = [
+        etcd_key.test,
+        etcd_key.test2,
+        etcd_key.test3
+    ]
+}
+
+output "test_range_export" {
+  value     = etcd_key_range_export.test
+}
@@ -0,0 +1,21 @@
+data "etcd_prefix_range_end" "import" {
+    key = "/import/"
+}
+
+resource "etcd_key_range_import" "test" {
+    key = data.etcd_prefix_range_end.import.key
+    range_end = data.etcd_prefix_range_end.import.range_end
+    file = "${path.module}/key-range-import.json"
+    format = "json"
+    prune = true
+}
+
+data "etcd_key_range" "import" {
+    key = data.etcd_prefix_range_end.import.key
+    range_end = data.etcd_prefix_range_end.import.range_end
+    depends_on = [etcd_key_range_import.test]
+}
+
+output "import" {
+  value     = data.etcd_key_range.import
+}
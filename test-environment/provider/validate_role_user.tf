@@ -24,11 +24,22 @@ resource "etcd_role" "testmore" {
         key = data.etcd_prefix_range_end.testmore2.key
         range_end = data.etcd_prefix_range_end.testmore2.range_end
     }
+
+    permissions {
+        permission = "readwrite"
+        key = "/testmore2/sub/"
+        prefix = true
+    }
+}
+
+output "testmore_effective_ranges" {
+  value     = etcd_role.testmore.effective_ranges
 }
 
 resource "etcd_user" "test" {
     username = "test"
     password = "hello"
+    verify_password = true
     roles = ["test", "testmore"]
 }
 
@@ -36,4 +47,99 @@ resource "etcd_user" "test2" {
     username = "test2"
     password = "hello"
     roles = []
-}
\ No newline at end of file
+}
+resource "etcd_user" "test3" {
+    username = "test3"
+    no_password = true
+    roles = ["test"]
+}
+
+data "etcd_user" "test3" {
+    username = etcd_user.test3.username
+    detect_auth_mode = true
+}
+
+output "test3_auth_mode" {
+  value     = {
+    resource = etcd_user.test3.auth_mode
+    data = data.etcd_user.test3.auth_mode
+  }
+}
+
+resource "etcd_role" "test_shared" {
+    name = "test_shared"
+
+    lifecycle {
+        ignore_changes = [permissions]
+    }
+}
+
+data "etcd_prefix_range_end" "test_shared" {
+    key = "/test_shared/"
+}
+
+resource "etcd_role_permission" "test_shared" {
+    role = etcd_role.test_shared.name
+    permission = "readwrite"
+    key = data.etcd_prefix_range_end.test_shared.key
+    range_end = data.etcd_prefix_range_end.test_shared.range_end
+}
+
+resource "etcd_user" "test_shared" {
+    username = "test_shared"
+    password = "hello"
+
+    lifecycle {
+        ignore_changes = [roles]
+    }
+}
+
+resource "etcd_user_role_binding" "test_shared" {
+    username = etcd_user.test_shared.username
+    role = etcd_role.test_shared.name
+}
+
+data "etcd_user" "test" {
+    username = etcd_user.test.username
+}
+
+data "etcd_role" "test" {
+    name = etcd_role.test.name
+}
+
+data "etcd_users" "all" {
+    depends_on = [etcd_user.test, etcd_user.test2]
+}
+
+data "etcd_roles" "all" {
+    depends_on = [etcd_role.test, etcd_role.testmore]
+}
+
+output "acls" {
+  value = {
+    user = data.etcd_user.test
+    role = data.etcd_role.test
+    users = data.etcd_users.all.usernames
+    roles = data.etcd_roles.all.names
+  }
+}
+
+data "etcd_access_check" "test_read" {
+    username = etcd_user.test.username
+    key = "/test2/hello"
+    operation = "read"
+}
+
+data "etcd_access_check" "test_write" {
+    username = etcd_user.test.username
+    key = "/testmore2/sub/"
+    prefix = true
+    operation = "write"
+}
+
+output "access_checks" {
+  value = {
+    read = data.etcd_access_check.test_read
+    write = data.etcd_access_check.test_write
+  }
+}
@@ -14,6 +14,24 @@ resource "etcd_synchronized_directory" "destination" {
     depends_on = [etcd_synchronized_directory.source]
 }
 
+resource "etcd_synchronized_directory" "manifest_source" {
+    directory = "${path.module}/dir-sync"
+    key_prefix = "/dir-sync-manifested/"
+    source = "directory"
+    recurrence = "onchange"
+    manifest_key = "/dir-sync-manifests/dir-sync-manifested"
+}
+
+resource "etcd_synchronized_directory" "manifest_destination" {
+    directory = "${path.module}/dir-sync-manifested-copy"
+    key_prefix = "/dir-sync-manifested/"
+    source = "key-prefix"
+    recurrence = "onchange"
+    manifest_key = "/dir-sync-manifests/dir-sync-manifested"
+
+    depends_on = [etcd_synchronized_directory.manifest_source]
+}
+
 data "etcd_prefix_range_end" "dir_sync" {
     key = "/dir-sync/"
 }
@@ -26,4 +44,26 @@ data "etcd_key_range" "dir_sync" {
 
 output "dir_sync" {
   value     = data.etcd_key_range.dir_sync
-}
\ No newline at end of file
+}
+resource "etcd_synchronized_directory" "compressed_source" {
+    directory = "${path.module}/dir-sync"
+    key_prefix = "/dir-sync-zstd/"
+    source = "directory"
+    recurrence = "onchange"
+    compression = "zstd"
+    max_file_size = 1048576
+    metadata = ["mode", "mtime"]
+    symlinks = "preserve"
+}
+
+resource "etcd_synchronized_directory" "compressed_destination" {
+    directory = "${path.module}/dir-sync-zstd-copy"
+    key_prefix = "/dir-sync-zstd/"
+    source = "key-prefix"
+    recurrence = "onchange"
+    compression = "zstd"
+    metadata = ["mode", "mtime"]
+    symlinks = "preserve"
+
+    depends_on = [etcd_synchronized_directory.compressed_source]
+}
@@ -0,0 +1,34 @@
+resource "etcd_key" "test_transaction" {
+    key = "/test_transaction/counter"
+    value = "1"
+}
+
+resource "etcd_transaction" "test" {
+    compare {
+        key = etcd_key.test_transaction.key
+        target = "value"
+        value = etcd_key.test_transaction.value
+    }
+
+    compare {
+        key = "/test_transaction/missing"
+        target = "create_revision"
+        value = "0"
+    }
+
+    success {
+        type = "put"
+        key = "/test_transaction/result"
+        value = "succeeded"
+    }
+
+    failure {
+        type = "put"
+        key = "/test_transaction/result"
+        value = "failed"
+    }
+}
+
+output "transaction_succeeded" {
+  value = etcd_transaction.test.succeeded
+}
@@ -0,0 +1,18 @@
+resource "etcd_key" "test_wait" {
+    key = "/test_wait/ready"
+    value = "ready-1"
+}
+
+resource "etcd_wait" "test_matches" {
+    key = etcd_key.test_wait.key
+    condition = "matches"
+    regex = "^ready-[0-9]+$"
+    timeout = "1m"
+}
+
+resource "etcd_wait" "test_absent" {
+    key = "/test_wait_absent/"
+    range_end = "/test_wait_absent0"
+    condition = "absent"
+    timeout = "1m"
+}